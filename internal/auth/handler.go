@@ -3,6 +3,7 @@ package auth
 import (
 	"log"
 	"teka-api/internal/models"
+	"teka-api/internal/referral"
 	"teka-api/pkg/database"
 	"teka-api/pkg/utils"
 
@@ -17,6 +18,9 @@ func RegisterController(c *fiber.Ctx) error {
 		Email      string `json:"email"`
 		Password   string `json:"password"`
 		ConfirmPwd string `json:"confirm_password"`
+
+		ReferralCode string `json:"referral_code"`
+		DeviceID     string `json:"device_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid payload"})
@@ -34,6 +38,13 @@ func RegisterController(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "phone already registered"})
 	}
 
+	// Kode referral opsional, tapi kalau diisi harus valid
+	if body.ReferralCode != "" {
+		if _, err := referral.FindReferrerID(database.DB, body.ReferralCode); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid referral code"})
+		}
+	}
+
 	// Simpan temp_user
 	hashed, _ := utils.HashPassword(body.Password)
 	temp := models.TempUser{
@@ -43,6 +54,12 @@ func RegisterController(c *fiber.Ctx) error {
 		Password:  hashed,
 		CreatedAt: utils.NowJakarta(),
 	}
+	if code := referral.NormalizeCode(body.ReferralCode); code != "" {
+		temp.ReferralCode = &code
+	}
+	if body.DeviceID != "" {
+		temp.DeviceID = &body.DeviceID
+	}
 	if err := SaveTempUser(&temp); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed save temp user"})
 	}
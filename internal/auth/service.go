@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"teka-api/internal/models"
+	"teka-api/internal/referral"
 	"teka-api/pkg/database"
 	"teka-api/pkg/utils"
 	"time"
@@ -62,6 +63,8 @@ func VerifyAndRegister(email, otp string) error {
 			Password:  temp.Password,
 			CreatedAt: utils.NowJakarta(),
 			UpdatedAt: utils.NowJakarta(),
+
+			RegisterDeviceID: temp.DeviceID,
 		}
 
 		if err := tx.Create(&user).Error; err != nil {
@@ -77,7 +80,25 @@ func VerifyAndRegister(email, otp string) error {
 			return err
 		}
 
-		// 5. Delete temp user
+		// 5. Kode referral milik user + catat pengajak (jika ada)
+		if _, err := referral.AssignCode(tx, int64(user.ID)); err != nil {
+			return err
+		}
+		if temp.ReferralCode != nil && *temp.ReferralCode != "" {
+			in := referral.CaptureInput{
+				RefereeID: int64(user.ID),
+				Code:      *temp.ReferralCode,
+				Phone:     temp.Phone,
+			}
+			if temp.DeviceID != nil {
+				in.DeviceID = *temp.DeviceID
+			}
+			if err := referral.Capture(tx, in); err != nil {
+				return err
+			}
+		}
+
+		// 6. Delete temp user
 		if err := DeleteTempUserTx(tx, email); err != nil {
 			return err
 		}
@@ -0,0 +1,103 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"teka-api/internal/models"
+)
+
+// availability_status_id di mitra_details
+const (
+	AvailabilityOffline int16 = 1
+	AvailabilityOnline  int16 = 2
+)
+
+// SetAvailability mitra toggle online / offline
+func (s *Service) SetAvailability(ctx context.Context, mitraID int64, online bool) error {
+	statusID := AvailabilityOffline
+	if online {
+		statusID = AvailabilityOnline
+
+		// hanya mitra terverifikasi KYC yang boleh online
+		v, err := s.Repo.GetMitraVerification(ctx, mitraID)
+		if err != nil {
+			return err
+		}
+		if v.VerificationStatus != VerificationVerified {
+			return errors.New("akun mitra belum terverifikasi, tunggu review dokumen selesai")
+		}
+	}
+	return s.Repo.SetAvailability(ctx, mitraID, statusID)
+}
+
+func (s *Service) GetWorkingHours(ctx context.Context, mitraID int64) ([]models.MitraWorkingHour, error) {
+	return s.Repo.GetWorkingHours(ctx, mitraID)
+}
+
+// UpdateWorkingHours validasi lalu ganti seluruh jadwal mingguan.
+// Jadwal kosong = mitra selalu bisa menerima order saat online.
+func (s *Service) UpdateWorkingHours(ctx context.Context, mitraID int64, hours []models.MitraWorkingHour) error {
+	for _, h := range hours {
+		if h.DayOfWeek < 0 || h.DayOfWeek > 6 {
+			return errors.New("day_of_week harus 0 (Minggu) sampai 6 (Sabtu)")
+		}
+
+		start, err := time.Parse("15:04", h.StartTime)
+		if err != nil {
+			return fmt.Errorf("start_time tidak valid: %s", h.StartTime)
+		}
+		end, err := time.Parse("15:04", h.EndTime)
+		if err != nil {
+			return fmt.Errorf("end_time tidak valid: %s", h.EndTime)
+		}
+		if !end.After(start) {
+			return errors.New("end_time harus setelah start_time")
+		}
+	}
+
+	return s.Repo.ReplaceWorkingHours(ctx, mitraID, hours)
+}
+
+// RunAvailabilityWorker offline-kan mitra yang sudah di luar jam kerja
+func (s *Service) RunAvailabilityWorker(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	log.Println("👷 Availability Worker started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👷 Availability Worker stopped")
+			return
+		case <-ticker.C:
+			ids, err := s.Repo.SetOfflineOutsideWorkingHours(ctx)
+			if err != nil {
+				log.Println("❌ availability worker error:", err)
+				continue
+			}
+
+			for _, id := range ids {
+				log.Printf("🌙 Mitra %d set offline (outside working hours)", id)
+				s.pushToUser(
+					id,
+					"Kamu Sekarang Offline",
+					"Jam kerja kamu sudah selesai, status otomatis diubah menjadi offline",
+					map[string]string{"type": "AVAILABILITY_OFFLINE"},
+				)
+			}
+		}
+	}
+}
+
+// UpdateLocation heartbeat lokasi mitra (REST / websocket)
+func (s *Service) UpdateLocation(ctx context.Context, mitraID int64, lat, lng float64) error {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 || (lat == 0 && lng == 0) {
+		return errors.New("koordinat tidak valid")
+	}
+	return s.Repo.UpdateMitraLocation(ctx, mitraID, lat, lng)
+}
@@ -0,0 +1,245 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"teka-api/internal/models"
+)
+
+// booking open tanpa offer selama ini dianggap gagal di-dispatch
+const bookingClaimStaleMinutes = 5
+
+// intParam baca global parameter angka bulat, fallback ke default
+func (s *Service) intParam(ctx context.Context, code string, def int) int {
+	val, err := s.Repo.GetGlobalParameter(ctx, code)
+	if err != nil || val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// CreateBooking simpan request terjadwal (status 6). Dispatch offer baru
+// dimulai oleh RunScheduledBookingWorker menjelang jadwal.
+func (s *Service) CreateBooking(
+	ctx context.Context,
+	customerID int64,
+	req BookingRequest,
+) (int64, error) {
+
+	if req.ScheduledAt.IsZero() {
+		return 0, errors.New("scheduled_at wajib diisi")
+	}
+
+	minLead := s.intParam(ctx, "BOOKING_MIN_LEAD_MINUTES", 60)
+	maxDays := s.intParam(ctx, "BOOKING_MAX_DAYS_AHEAD", 30)
+	slotMinutes := s.intParam(ctx, "BOOKING_SLOT_MINUTES", 60)
+
+	now := time.Now()
+	if req.ScheduledAt.Before(now.Add(time.Duration(minLead) * time.Minute)) {
+		return 0, fmt.Errorf("booking minimal %d menit dari sekarang", minLead)
+	}
+	if req.ScheduledAt.After(now.AddDate(0, 0, maxDays)) {
+		return 0, fmt.Errorf("booking maksimal %d hari ke depan", maxDays)
+	}
+
+	quote, err := s.resolveQuote(ctx, customerID, req.QuoteID)
+	if err != nil {
+		return 0, err
+	}
+
+	var subCatID int64
+	if quote.JobSubCategoryID != nil {
+		subCatID = *quote.JobSubCategoryID
+	}
+
+	overlap, err := s.Repo.HasOverlappingBooking(ctx, customerID, req.ScheduledAt, slotMinutes)
+	if err != nil {
+		return 0, err
+	}
+	if overlap {
+		return 0, errors.New("kamu sudah punya booking di jam tersebut")
+	}
+
+	radius, err := s.Repo.GetMaxRadius(ctx)
+	if err != nil {
+		radius = 10
+	}
+
+	capacity, booked, err := s.Repo.CountBookingSlot(
+		ctx,
+		quote.JobCategoryID,
+		subCatID,
+		req.Latitude,
+		req.Longitude,
+		radius,
+		req.ScheduledAt,
+		slotMinutes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if booked >= capacity {
+		return 0, errors.New("slot jadwal sudah penuh, silakan pilih waktu lain")
+	}
+
+	scheduledAt := req.ScheduledAt
+	return s.Repo.CreateCustomerRequest(ctx, models.CustomerRequest{
+		CustomerID:   customerID,
+		Keluhan:      req.Keluhan,
+		Latitude:     req.Latitude,
+		Longitude:    req.Longitude,
+		Radius:       req.Radius,
+		SearchRadius: radius,
+		ScheduledAt:  &scheduledAt,
+		StatusID:     RequestStatusScheduled,
+		QuoteID:      &quote.ID,
+	})
+}
+
+// dispatchBooking mulai pencarian dokter untuk booking yang sudah jatuh tempo
+func (s *Service) dispatchBooking(ctx context.Context, req models.CustomerRequest) {
+	var subCatID int64
+	if req.JobSubCategoryID != nil {
+		subCatID = *req.JobSubCategoryID
+	}
+
+	doctors, err := s.Repo.SearchDoctors(
+		ctx,
+		req.JobCategoryID,
+		subCatID,
+		req.Latitude,
+		req.Longitude,
+		req.SearchRadius,
+		0,
+	)
+	if err != nil {
+		log.Printf("❌ booking %d: search doctors: %v", req.ID, err)
+		s.requeueBooking(ctx, req.ID)
+		return
+	}
+
+	// belum ada dokter di radius awal → langsung perluas radius / expire
+	if len(doctors) == 0 {
+		go s.expandSearchOrExpire(req.ID)
+		return
+	}
+
+	dispatch := s.GetDispatchConfig(ctx, req.JobCategoryID)
+	if dispatch.Mode == DispatchFanout && len(doctors) > dispatch.BatchSize {
+		doctors = doctors[:dispatch.BatchSize]
+	}
+
+	sent, err := s.Repo.CreateOffers(ctx, req.ID, doctors, dispatch.BatchSize)
+	if err != nil {
+		log.Printf("❌ booking %d: create offers: %v", req.ID, err)
+		s.requeueBooking(ctx, req.ID)
+		return
+	}
+
+	s.offersSent(req.ID, sent)
+
+	log.Printf("📅 Booking %d dispatched to %d doctor(s)", req.ID, len(doctors))
+}
+
+// requeueBooking dispatch gagal setelah booking diklaim (status sudah open):
+// kembalikan ke terjadwal supaya diklaim ulang tick berikutnya. Jika tidak
+// bisa dikembalikan, serahkan ke perluasan radius / expire supaya request
+// tidak menggantung tanpa offer.
+func (s *Service) requeueBooking(ctx context.Context, requestID int64) {
+	requeued, err := s.Repo.RequeueBooking(ctx, requestID)
+	if err != nil {
+		log.Printf("❌ booking %d: requeue: %v", requestID, err)
+	}
+	if requeued {
+		log.Printf("📅 Booking %d requeued for next dispatch attempt", requestID)
+		return
+	}
+	go s.expandSearchOrExpire(requestID)
+}
+
+// RunScheduledBookingWorker mulai dispatch booking BOOKING_DISPATCH_LEAD_MINUTES
+// sebelum jadwal dan kirim reminder BOOKING_REMINDER_MINUTES sebelum jadwal.
+func (s *Service) RunScheduledBookingWorker(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	log.Println("👷 Scheduled Booking Worker started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👷 Scheduled Booking Worker stopped")
+			return
+		case <-ticker.C:
+			leadMinutes := s.intParam(ctx, "BOOKING_DISPATCH_LEAD_MINUTES", 30)
+			reminderMinutes := s.intParam(ctx, "BOOKING_REMINDER_MINUTES", 15)
+
+			// 1. Booking yang diklaim tapi tidak pernah di-dispatch (crash /
+			//    ganti leader setelah klaim) → kembalikan ke antrian
+			stale, err := s.Repo.RequeueStaleBookings(ctx, bookingClaimStaleMinutes)
+			if err != nil {
+				log.Println("❌ booking sweep error:", err)
+			}
+			for _, id := range stale {
+				log.Printf("📅 Booking %d claimed without offers, requeued", id)
+			}
+
+			// 2. Booking yang sudah waktunya dicarikan dokter
+			due, err := s.Repo.ClaimDueBookings(ctx, leadMinutes)
+			if err != nil {
+				log.Println("❌ booking worker error:", err)
+			}
+			for _, req := range due {
+				s.dispatchBooking(ctx, req)
+			}
+
+			// 3. Reminder customer + dokter
+			reminders, err := s.Repo.ClaimBookingReminders(ctx, reminderMinutes)
+			if err != nil {
+				log.Println("❌ booking reminder error:", err)
+				continue
+			}
+			for _, rm := range reminders {
+				s.sendBookingReminder(rm)
+			}
+		}
+	}
+}
+
+// sendBookingReminder push FCM pengingat jadwal ke customer dan mitra (jika sudah match)
+func (s *Service) sendBookingReminder(rm BookingReminder) {
+	at := rm.ScheduledAt.In(time.Local).Format("15:04")
+	data := map[string]string{
+		"type":       "BOOKING_REMINDER",
+		"request_id": strconv.FormatInt(rm.ID, 10),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vertical := s.Vertical(ctx, rm.JobCategoryID)
+
+	s.pushToUser(
+		rm.CustomerID,
+		"Pengingat Jadwal Kunjungan ⏰",
+		vertical.Text(CopyBookingReminderBody, "time", at),
+		data,
+	)
+
+	if rm.MatchedMitraID != nil && *rm.MatchedMitraID != 0 {
+		s.pushToUser(
+			*rm.MatchedMitraID,
+			"Pengingat Jadwal Kunjungan ⏰",
+			vertical.Text(CopyBookingReminderMitra, "time", at),
+			data,
+		)
+	}
+}
@@ -0,0 +1,316 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"teka-api/internal/realtime/redis"
+
+	"gorm.io/gorm"
+)
+
+// Pihak yang membatalkan service order
+const (
+	CancelledByCustomer = "CUSTOMER"
+	CancelledByMitra    = "MITRA"
+)
+
+// CancelReason katalog alasan pembatalan
+type CancelReason struct {
+	Code     string `json:"code"`
+	Label    string `json:"label"`
+	WaiveFee bool   `json:"waive_fee"` // alasan ini tidak dikenakan biaya
+}
+
+var customerCancelReasons = []CancelReason{
+	{Code: "CHANGED_MIND", Label: "Berubah pikiran"},
+	{Code: "MITRA_TOO_LONG", Label: "{mitra} terlalu lama datang", WaiveFee: true},
+	{Code: "MITRA_UNREACHABLE", Label: "{mitra} tidak bisa dihubungi", WaiveFee: true},
+	{Code: "WRONG_ADDRESS", Label: "Salah memasukkan alamat"},
+	{Code: "FOUND_OTHER", Label: "Sudah mendapat penanganan lain"},
+	{Code: "OTHER", Label: "Lainnya"},
+}
+
+var mitraCancelReasons = []CancelReason{
+	{Code: "EMERGENCY", Label: "Keadaan darurat"},
+	{Code: "VEHICLE_PROBLEM", Label: "Kendala kendaraan"},
+	{Code: "PATIENT_UNREACHABLE", Label: "Pasien tidak bisa dihubungi", WaiveFee: true},
+	{Code: "UNSAFE_LOCATION", Label: "Lokasi tidak aman", WaiveFee: true},
+	{Code: "OTHER", Label: "Lainnya"},
+}
+
+// suffix parameter biaya batal per status order
+var cancelFeeStatusSuffix = map[int16]string{
+	1: "ACCEPTED",
+	2: "OTW",
+	3: "ARRIVED",
+}
+
+// GetCancelReasons katalog alasan batal untuk customer / mitra, label
+// disesuaikan sebutan mitra vertical (jobCategoryID 0 = umum)
+func (s *Service) GetCancelReasons(ctx context.Context, cancelledBy string, jobCategoryID int64) []CancelReason {
+	catalog := customerCancelReasons
+	if cancelledBy == CancelledByMitra {
+		catalog = mitraCancelReasons
+	}
+
+	label := "Mitra"
+	if jobCategoryID != 0 {
+		label = s.Vertical(ctx, jobCategoryID).MitraLabel
+	}
+
+	reasons := make([]CancelReason, len(catalog))
+	for i, r := range catalog {
+		r.Label = strings.ReplaceAll(r.Label, "{mitra}", label)
+		reasons[i] = r
+	}
+	return reasons
+}
+
+func findCancelReason(reasons []CancelReason, code string) (CancelReason, bool) {
+	for _, r := range reasons {
+		if r.Code == code {
+			return r, true
+		}
+	}
+	return CancelReason{}, false
+}
+
+// cancelFee biaya batal dari CANCEL_FEE_<CUSTOMER|MITRA>_<STATUS>
+func (s *Service) cancelFee(ctx context.Context, cancelledBy string, statusID int16) int64 {
+	suffix, ok := cancelFeeStatusSuffix[statusID]
+	if !ok {
+		return 0
+	}
+
+	code := fmt.Sprintf("CANCEL_FEE_%s_%s", cancelledBy, suffix)
+	val, err := s.Repo.GetGlobalParameter(ctx, code)
+	if err != nil || val == "" {
+		return 0
+	}
+
+	fee, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+	if err != nil || fee < 0 {
+		return 0
+	}
+	return fee
+}
+
+// cancelShareAndRedispatch CANCEL_FEE_MITRA_SHARE_PERCENT (default 100)
+// dan CANCEL_AUTO_REDISPATCH (default true)
+func (s *Service) cancelShareAndRedispatch(ctx context.Context) (share int64, redispatch bool) {
+	share = 100
+	if val, err := s.Repo.GetGlobalParameter(ctx, "CANCEL_FEE_MITRA_SHARE_PERCENT"); err == nil && val != "" {
+		if n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil && n >= 0 && n <= 100 {
+			share = n
+		}
+	}
+
+	redispatch = true
+	if val, err := s.Repo.GetGlobalParameter(ctx, "CANCEL_AUTO_REDISPATCH"); err == nil && val != "" {
+		if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+			redispatch = b
+		}
+	}
+
+	return share, redispatch
+}
+
+// CancelServiceOrder pembatalan setelah dokter menerima order (status 1-3)
+// oleh customer atau mitra pemilik order.
+func (s *Service) CancelServiceOrder(
+	ctx context.Context,
+	orderID int64,
+	userID int64,
+	cancelledBy string,
+	reasonCode string,
+	note string,
+) (CancelServiceOrderResult, error) {
+
+	order, err := s.Repo.GetOrderForCancel(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return CancelServiceOrderResult{}, errors.New("order tidak ditemukan")
+		}
+		return CancelServiceOrderResult{}, err
+	}
+
+	owner := order.CustomerID
+	if cancelledBy == CancelledByMitra {
+		owner = order.MitraID
+	}
+	if owner != userID {
+		return CancelServiceOrderResult{}, errors.New("order tidak ditemukan")
+	}
+
+	if _, ok := cancelFeeStatusSuffix[order.StatusID]; !ok {
+		return CancelServiceOrderResult{}, errors.New("order tidak dapat dibatalkan pada status ini")
+	}
+
+	reasonCode = strings.ToUpper(strings.TrimSpace(reasonCode))
+	reason, ok := findCancelReason(s.GetCancelReasons(ctx, cancelledBy, order.JobCategoryID), reasonCode)
+	if !ok {
+		return CancelServiceOrderResult{}, errors.New("invalid reason_code")
+	}
+
+	note = strings.TrimSpace(note)
+	if reason.Code == "OTHER" && note == "" {
+		return CancelServiceOrderResult{}, errors.New("note wajib diisi untuk alasan lainnya")
+	}
+
+	var fee int64
+	if !reason.WaiveFee {
+		fee = s.cancelFee(ctx, cancelledBy, order.StatusID)
+	}
+
+	share, redispatch := s.cancelShareAndRedispatch(ctx)
+
+	result, err := s.Repo.CancelServiceOrder(ctx, CancelServiceOrderInput{
+		Order:        order,
+		CancelledBy:  cancelledBy,
+		ReasonCode:   reason.Code,
+		ReasonNote:   note,
+		Fee:          fee,
+		SharePercent: share,
+		Redispatch:   cancelledBy == CancelledByMitra && redispatch,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	log.Printf("🛑 Order %d cancelled by %s %d (reason: %s, fee: %d)",
+		orderID, cancelledBy, userID, reason.Code, result.FeeCharged)
+
+	s.Hub.Broadcast(int(orderID), map[string]interface{}{
+		"event":        "order_status_updated",
+		"order_id":     orderID,
+		"status_id":    5,
+		"cancelled_by": cancelledBy,
+		"reason_code":  reason.Code,
+		"redispatched": result.Redispatched,
+	})
+
+	if redis.Rdb != nil {
+		redis.Rdb.Del(ctx, fmt.Sprintf("order_chat:%d", orderID))
+	}
+
+	s.notifyOrderCancelled(order, cancelledBy, reason, result)
+
+	if result.Redispatched {
+		go s.redispatchRequest(order.RequestID)
+	}
+
+	return result, nil
+}
+
+// notifyOrderCancelled FCM ke pihak lawan
+func (s *Service) notifyOrderCancelled(
+	order CancelOrderInfo,
+	cancelledBy string,
+	reason CancelReason,
+	result CancelServiceOrderResult,
+) {
+	data := map[string]string{
+		"type":         "ORDER_CANCELLED",
+		"order_id":     strconv.FormatInt(order.ID, 10),
+		"request_id":   strconv.FormatInt(order.RequestID, 10),
+		"cancelled_by": cancelledBy,
+		"reason_code":  reason.Code,
+	}
+
+	if cancelledBy == CancelledByCustomer {
+		body := fmt.Sprintf("Customer membatalkan order %s (%s)", order.OrderNumber, reason.Label)
+		if result.Compensation > 0 {
+			body += fmt.Sprintf(". Kompensasi Rp%d masuk ke saldo kamu", result.Compensation)
+		}
+		s.pushToUser(order.MitraID, "Order Dibatalkan", body, data)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vertical := s.Vertical(ctx, order.JobCategoryID)
+
+	body := fmt.Sprintf("%s (%s)", vertical.Text(CopyMitraCancelledBody, "order", order.OrderNumber), reason.Label)
+	if result.Redispatched {
+		body += vertical.Text(CopyRedispatchSuffix)
+	}
+	s.pushToUser(order.CustomerID, vertical.Text(CopyMitraCancelledTitle), body, data)
+}
+
+// redispatchRequest cari dokter pengganti setelah dokter membatalkan.
+// Dokter yang pernah mendapat offer request ini tidak dikirimi lagi.
+func (s *Service) redispatchRequest(requestID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := s.Repo.GetCustomerRequest(ctx, requestID)
+	if err != nil {
+		log.Printf("❌ redispatch: get request %d: %v", requestID, err)
+		return
+	}
+	if req.StatusID != RequestStatusOpen {
+		return
+	}
+
+	s.publishRequestEvent(requestID, RequestEventRedispatching, nil)
+
+	var subCatID int64
+	if req.JobSubCategoryID != nil {
+		subCatID = *req.JobSubCategoryID
+	}
+
+	radius := req.SearchRadius
+	if radius <= 0 {
+		if radius, err = s.Repo.GetMaxRadius(ctx); err != nil {
+			radius = 10
+		}
+	}
+
+	doctors, err := s.Repo.SearchDoctors(
+		ctx,
+		req.JobCategoryID,
+		subCatID,
+		req.Latitude,
+		req.Longitude,
+		radius,
+		requestID,
+	)
+	if err != nil {
+		log.Printf("❌ redispatch: search request %d: %v", requestID, err)
+		s.expandSearchOrExpire(requestID)
+		return
+	}
+
+	// tidak ada dokter lain di radius sekarang → perluas / expire
+	if len(doctors) == 0 {
+		s.expandSearchOrExpire(requestID)
+		return
+	}
+
+	dispatch := s.GetDispatchConfig(ctx, req.JobCategoryID)
+	if dispatch.Mode == DispatchFanout && len(doctors) > dispatch.BatchSize {
+		doctors = doctors[:dispatch.BatchSize]
+	}
+
+	sent, err := s.Repo.AppendOffers(ctx, requestID, radius, doctors, dispatch.BatchSize)
+	if err != nil {
+		log.Printf("❌ redispatch: append offers request %d: %v", requestID, err)
+		s.expandSearchOrExpire(requestID)
+		return
+	}
+
+	// tidak ada offer yang aktif → jangan biarkan request open tanpa offer
+	if len(sent) == 0 {
+		s.expandSearchOrExpire(requestID)
+		return
+	}
+
+	log.Printf("🔁 Request %d re-dispatched to %d doctor(s)", requestID, len(sent))
+	s.offersSent(requestID, sent)
+}
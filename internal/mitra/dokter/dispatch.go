@@ -0,0 +1,204 @@
+package dokter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode pengiriman offer ke mitra
+const (
+	DispatchSequential = "SEQUENTIAL" // satu per satu sesuai urutan
+	DispatchFanout     = "FANOUT"     // langsung ke top-N sekaligus, tanpa antrian
+	DispatchWave       = "WAVE"       // gelombang N mitra, lanjut ke gelombang berikutnya jika habis waktu
+)
+
+// DispatchConfig hasil resolve global parameter per job category
+type DispatchConfig struct {
+	Mode      string
+	BatchSize int
+}
+
+// GetDispatchConfig ambil strategi dispatch untuk job category.
+// Urutan lookup: DISPATCH_MODE_CAT_<id> → DISPATCH_MODE → SEQUENTIAL
+// dan DISPATCH_BATCH_SIZE_CAT_<id> → DISPATCH_BATCH_SIZE → 3.
+func (s *Service) GetDispatchConfig(ctx context.Context, jobCategoryID int64) DispatchConfig {
+	cfg := DispatchConfig{Mode: DispatchSequential, BatchSize: 3}
+
+	if val := s.categoryParameter(ctx, "DISPATCH_MODE", jobCategoryID); val != "" {
+		switch mode := strings.ToUpper(strings.TrimSpace(val)); mode {
+		case DispatchSequential, DispatchFanout, DispatchWave:
+			cfg.Mode = mode
+		}
+	}
+
+	if val := s.categoryParameter(ctx, "DISPATCH_BATCH_SIZE", jobCategoryID); val != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(val)); err == nil && n > 0 {
+			cfg.BatchSize = n
+		}
+	}
+
+	if cfg.Mode == DispatchSequential {
+		cfg.BatchSize = 1
+	}
+
+	return cfg
+}
+
+// categoryParameter cari parameter khusus kategori dulu, fallback ke parameter umum
+func (s *Service) categoryParameter(ctx context.Context, code string, jobCategoryID int64) string {
+	if val, err := s.Repo.GetGlobalParameter(ctx, fmt.Sprintf("%s_CAT_%d", code, jobCategoryID)); err == nil && val != "" {
+		return val
+	}
+	if val, err := s.Repo.GetGlobalParameter(ctx, code); err == nil {
+		return val
+	}
+	return ""
+}
+
+// Status customer_requests
+const (
+	RequestStatusOpen      int16 = 1
+	RequestStatusExpired   int16 = 5
+	RequestStatusScheduled int16 = 6
+)
+
+// radiusExpansion baca RADIUS_EXPANSION_STEP_KM (default 5) dan
+// RADIUS_EXPANSION_MAX_KM (default 2x MAX_RADIUS)
+func (s *Service) radiusExpansion(ctx context.Context) (step, ceiling float64) {
+	maxRadius, err := s.Repo.GetMaxRadius(ctx)
+	if err != nil {
+		maxRadius = 10
+	}
+
+	step = 5
+	if val, err := s.Repo.GetGlobalParameter(ctx, "RADIUS_EXPANSION_STEP_KM"); err == nil && val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f > 0 {
+			step = f
+		}
+	}
+
+	ceiling = maxRadius * 2
+	if val, err := s.Repo.GetGlobalParameter(ctx, "RADIUS_EXPANSION_MAX_KM"); err == nil && val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f > 0 {
+			ceiling = f
+		}
+	}
+
+	return step, ceiling
+}
+
+// expandSearchOrExpire dipanggil saat antrian offer habis: cari ulang dokter
+// dengan radius yang makin lebar sampai batas, jika tetap kosong request
+// di-expire dan customer diberi tahu.
+func (s *Service) expandSearchOrExpire(requestID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := s.Repo.GetCustomerRequest(ctx, requestID)
+	if err != nil {
+		log.Printf("❌ expand search: get request %d: %v", requestID, err)
+		return
+	}
+	if req.StatusID != RequestStatusOpen {
+		return
+	}
+
+	step, ceiling := s.radiusExpansion(ctx)
+	radius := req.SearchRadius
+	if radius <= 0 {
+		if radius, err = s.Repo.GetMaxRadius(ctx); err != nil {
+			radius = 10
+		}
+	}
+
+	var subCatID int64
+	if req.JobSubCategoryID != nil {
+		subCatID = *req.JobSubCategoryID
+	}
+
+	dispatch := s.GetDispatchConfig(ctx, req.JobCategoryID)
+
+	for radius < ceiling {
+		radius = math.Min(radius+step, ceiling)
+
+		doctors, err := s.Repo.SearchDoctors(
+			ctx,
+			req.JobCategoryID,
+			subCatID,
+			req.Latitude,
+			req.Longitude,
+			radius,
+			requestID,
+		)
+		if err != nil {
+			log.Printf("❌ expand search: request %d radius %.1f: %v", requestID, radius, err)
+			return
+		}
+		if len(doctors) == 0 {
+			continue
+		}
+
+		if dispatch.Mode == DispatchFanout && len(doctors) > dispatch.BatchSize {
+			doctors = doctors[:dispatch.BatchSize]
+		}
+
+		sent, err := s.Repo.AppendOffers(ctx, requestID, radius, doctors, dispatch.BatchSize)
+		if err != nil {
+			log.Printf("❌ expand search: append offers request %d: %v", requestID, err)
+			return
+		}
+
+		if len(sent) == 0 {
+			// request sudah tidak open / antrian sudah diisi proses lain
+			return
+		}
+
+		log.Printf("📡 Request %d expanded to %.1f km, %d new doctor(s)", requestID, radius, len(doctors))
+		s.publishRequestEvent(requestID, RequestEventSearchingWider, map[string]interface{}{
+			"radius_km": radius,
+		})
+		s.offersSent(requestID, sent)
+		return
+	}
+
+	expired, err := s.Repo.ExpireCustomerRequest(ctx, requestID)
+	if err != nil {
+		log.Printf("❌ expire request %d: %v", requestID, err)
+		return
+	}
+	if !expired {
+		return
+	}
+
+	log.Printf("⌛ Request %d expired, no doctor found up to %.1f km", requestID, ceiling)
+	s.notifyRequestExpired(ctx, req.CustomerID, req.JobCategoryID, requestID)
+}
+
+// notifyRequestExpired FCM + websocket ke customer bahwa mitra tidak ditemukan
+func (s *Service) notifyRequestExpired(ctx context.Context, customerID, jobCategoryID, requestID int64) {
+	vertical := s.Vertical(ctx, jobCategoryID)
+	s.pushToUser(
+		customerID,
+		vertical.Text(CopyNotFoundTitle),
+		vertical.Text(CopyNotFoundBody),
+		map[string]string{
+			"type":       "REQUEST_EXPIRED",
+			"request_id": strconv.FormatInt(requestID, 10),
+		},
+	)
+
+	s.Users.Send(customerID, map[string]interface{}{
+		"event":      "request_expired",
+		"request_id": requestID,
+		"status_id":  RequestStatusExpired,
+	})
+
+	s.publishRequestEvent(requestID, RequestEventNoDoctorFound, map[string]interface{}{
+		"status_id": RequestStatusExpired,
+	})
+}
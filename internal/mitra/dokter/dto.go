@@ -1,20 +1,50 @@
 package dokter
 
+import (
+	"time"
+
+	"teka-api/pkg/helper"
+)
+
+// SearchDoctorRequest kategori & harga diambil dari quote (POST /customer/quotes)
 type SearchDoctorRequest struct {
-	JobCategoryID    int64   `json:"job_category_id"`
-	JobSubCategoryID int64   `json:"job_sub_category_id"`
-	Keluhan          string  `json:"keluhan"`
-	Latitude         float64 `json:"latitude"`
-	Longitude        float64 `json:"longitude"`
-	Radius           float64 `json:"radius"`
-	Price            float64 `json:"price"`
-	VoucherID        int64   `json:"voucher_id"`
-	VoucherValue     float64 `json:"voucher_value"`
-	PlatformFee      float64 `json:"platform_fee"`
-	THRBonus         float64 `json:"thr_bonus"`
+	QuoteID   string  `json:"quote_id"`
+	Keluhan   string  `json:"keluhan"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    float64 `json:"radius"`
+}
+
+// QuoteRequest minta rincian harga layanan
+type QuoteRequest struct {
+	JobCategoryID    int64  `json:"job_category_id"`
+	JobSubCategoryID int64  `json:"job_sub_category_id"`
+	VoucherID        int64  `json:"voucher_id"`
+	VoucherCode      string `json:"voucher_code"` // alternatif voucher_id, mis. kode sekali pakai
+}
+
+// BookingRequest booking home visit terjadwal
+type BookingRequest struct {
+	SearchDoctorRequest
+	ScheduledAt time.Time `json:"scheduled_at"`
 }
 
 type CompleteOrderTxData struct {
 	Note        string
-	Attachments []string
+	Attachments []OrderAttachmentFile
+}
+
+// OrderAttachmentFile file lampiran order yang sudah tersimpan + tipenya
+type OrderAttachmentFile struct {
+	Type string // photo | document | signature
+	File helper.StoredFile
+}
+
+// OfferTarget mitra yang baru diaktifkan offer-nya
+type OfferTarget struct {
+	OfferID   int64
+	MitraID   int64
+	Nama      string `gorm:"column:nama"`
+	Sequence  int
+	ExpiredAt *time.Time
 }
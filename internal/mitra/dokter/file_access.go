@@ -0,0 +1,66 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"teka-api/internal/models"
+)
+
+// ErrFileForbidden viewer bukan pemilik, lawan transaksi, maupun admin
+var ErrFileForbidden = errors.New("akses file ditolak")
+
+// IsAdmin cek role admin; error dianggap bukan admin
+func (s *Service) IsAdmin(ctx context.Context, userID int64) bool {
+	ok, err := s.Repo.IsAdmin(ctx, userID)
+	if err != nil {
+		log.Printf("❌ check admin %d: %v", userID, err)
+		return false
+	}
+	return ok
+}
+
+// GetDocumentForViewer dokumen KYC hanya untuk pemilik atau admin
+func (s *Service) GetDocumentForViewer(ctx context.Context, viewerID, docID int64) (models.MitraDocument, error) {
+	doc, err := s.Repo.GetDocument(ctx, docID)
+	if err != nil {
+		return doc, err
+	}
+	if doc.UserID != viewerID && !s.IsAdmin(ctx, viewerID) {
+		return models.MitraDocument{}, ErrFileForbidden
+	}
+	return doc, nil
+}
+
+// GetAttachmentForViewer lampiran order untuk customer / mitra order atau admin
+func (s *Service) GetAttachmentForViewer(ctx context.Context, viewerID, attachmentID int64) (OrderAttachmentRow, error) {
+	att, err := s.Repo.GetOrderAttachment(ctx, attachmentID)
+	if err != nil {
+		return att, err
+	}
+	if att.CustomerID != viewerID && att.MitraID != viewerID && !s.IsAdmin(ctx, viewerID) {
+		return OrderAttachmentRow{}, ErrFileForbidden
+	}
+	return att, nil
+}
+
+// GetMitraPhotoForViewer foto profil mitra untuk mitra sendiri, customer yang
+// punya order dengan mitra tersebut, atau admin
+func (s *Service) GetMitraPhotoForViewer(ctx context.Context, viewerID, mitraID int64) (*string, error) {
+	if viewerID != mitraID {
+		ok, err := s.Repo.HasOrderWithMitra(ctx, viewerID, mitraID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok && !s.IsAdmin(ctx, viewerID) {
+			return nil, ErrFileForbidden
+		}
+	}
+
+	row, err := s.Repo.GetMitraProfile(ctx, mitraID)
+	if err != nil {
+		return nil, err
+	}
+	return row.PhotoKey, nil
+}
@@ -0,0 +1,21 @@
+package dokter
+
+import "math"
+
+// kmPerDegreeLat jarak 1 derajat lintang (km)
+const kmPerDegreeLat = 111.045
+
+// boundingBox kotak lat/lng yang pasti memuat lingkaran radiusKm dari titik
+// (lat, lng). Dipakai sebagai prefilter index sebelum hitung haversine.
+func boundingBox(lat, lng, radiusKm float64) (minLat, minLng, maxLat, maxLng float64) {
+	dLat := radiusKm / kmPerDegreeLat
+
+	// dekat kutub derajat bujur makin sempit → batasi supaya tidak membagi nol
+	cosLat := math.Cos(lat * math.Pi / 180)
+	if cosLat < 0.01 {
+		cosLat = 0.01
+	}
+	dLng := radiusKm / (kmPerDegreeLat * cosLat)
+
+	return lat - dLat, lng - dLng, lat + dLat, lng + dLng
+}
@@ -2,11 +2,13 @@ package dokter
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"teka-api/internal/models"
 	"teka-api/pkg/helper"
 	"teka-api/pkg/middleware"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
@@ -54,52 +57,426 @@ func (h *Handler) RegisterMitra(c *fiber.Ctx) error {
 	userDir := fmt.Sprintf("user-%d-%s", userID, helper.SanitizeFileName(userName))
 
 	// Map untuk menyimpan semua file yang di-upload
-	files := make(map[string]string)
+	files := make(map[string]helper.StoredFile)
 
-	// Daftar field file yang diharapkan
-	fileFields := []string{"foto", "foto_ktp", "foto_selfie_ktp", "str", "sip"}
+	// Daftar field file wajib sesuai vertical (job_categories.required_documents)
+	if req.JobCategoryID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "job_category_id wajib diisi"})
+	}
+	fileFields := h.Service.Vertical(c.Context(), int64(req.JobCategoryID)).RequiredDocuments
+
+	var missing []string
+	for _, field := range fileFields {
+		if fh, err := c.FormFile(field); err != nil || fh == nil {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "dokumen wajib belum lengkap",
+			"missing": missing,
+		})
+	}
 
-	bucket := os.Getenv("S3_BUCKET")
+	// Nomor & masa berlaku lisensi: field <doc>_number dan <doc>_expires_at
+	licenses := make(map[string]LicenseInfo)
+	for _, field := range fileFields {
+		license, err := h.Service.ParseLicense(
+			c.Context(),
+			field,
+			c.FormValue(field+"_number"),
+			c.FormValue(field+"_expires_at"),
+		)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		licenses[field] = license
+	}
 
 	for _, field := range fileFields {
 		if fileHeader, err := c.FormFile(field); err == nil && fileHeader != nil {
-			path, err := helper.UploadFileToMinio(
-				h.MinioClient,
-				bucket, // ✅ BENAR
-				userDir,
-				fileHeader,
-			)
+			stored, err := h.uploadMitraFile(userDir, fileHeader)
 			if err != nil {
-				return c.Status(500).JSON(fiber.Map{
+				return c.Status(uploadErrorStatus(err)).JSON(fiber.Map{
 					"error": fmt.Sprintf("failed to upload %s: %v", field, err),
 				})
 			}
-			// Construct full S3 URL using public endpoint
-			publicURL := os.Getenv("S3_PUBLIC_URL")
-			if publicURL == "" {
-				// Fallback to upload endpoint if public URL not set
-				publicURL = fmt.Sprintf("https://%s/%s", os.Getenv("S3_ENDPOINT"), bucket)
-			}
-			fullURL := fmt.Sprintf("%s/%s", publicURL, path)
-			files[field] = fullURL
+			files[field] = stored
 		}
 	}
 
 	// Simpan data ke DB via service
-	if err := h.Service.RegisterMitra(userID, *req, files); err != nil {
+	if err := h.Service.RegisterMitra(userID, *req, files, licenses); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(fiber.Map{"message": "Mitra registration submitted"})
 }
 
+// uploadMitraFile validasi & upload file mitra ke bucket private.
+// File dibaca lewat presigned URL, bukan URL publik.
+func (h *Handler) uploadMitraFile(userDir string, fileHeader *multipart.FileHeader) (helper.StoredFile, error) {
+	return helper.UploadFileToMinio(
+		h.MinioClient,
+		mitraBucket(),
+		userDir,
+		fileHeader,
+		maxUploadBytes,
+	)
+}
+
+// START KYC
+// GetMyVerification status verifikasi KYC + dokumen mitra
+func (h *Handler) GetMyVerification(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	v, err := h.Service.GetVerification(c.Context(), int64(mitraID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "mitra belum terdaftar"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignDocuments(v.Documents)
+
+	return c.JSON(fiber.Map{"data": v})
+}
+
+// ReuploadDocument upload ulang dokumen yang ditolak / perpanjangan lisensi
+// (multipart field "file", lisensi juga "license_number" & "expires_at")
+func (h *Handler) ReuploadDocument(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	docType := strings.ToLower(strings.TrimSpace(c.Params("doc_type")))
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil || fileHeader == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "file wajib diisi"})
+	}
+
+	license, err := h.Service.ParseLicense(c.Context(), docType, c.FormValue("license_number"), c.FormValue("expires_at"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userName := "unknown"
+	if nameVal := c.Locals("nama"); nameVal != nil {
+		userName = nameVal.(string)
+	}
+	userDir := fmt.Sprintf("user-%d-%s", mitraID, helper.SanitizeFileName(userName))
+
+	stored, err := h.uploadMitraFile(userDir, fileHeader)
+	if err != nil {
+		return c.Status(uploadErrorStatus(err)).JSON(fiber.Map{"error": fmt.Sprintf("failed to upload %s: %v", docType, err)})
+	}
+
+	v, err := h.Service.ReuploadDocument(c.Context(), int64(mitraID), docType, stored, license)
+	if err != nil {
+		h.removeStoredFile(mitraBucket(), stored)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignDocuments(v.Documents)
+
+	return c.JSON(fiber.Map{
+		"message": "document uploaded",
+		"data":    v,
+	})
+}
+
+// GetDocumentReviewQueue antrian review dokumen mitra (admin)
+func (h *Handler) GetDocumentReviewQueue(c *fiber.Ctx) error {
+	docs, err := h.Service.GetDocumentReviewQueue(
+		c.Context(),
+		c.Query("status"),
+		c.QueryInt("limit", 20),
+		c.QueryInt("offset", 0),
+	)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	for i := range docs {
+		h.presignDocument(&docs[i].MitraDocument)
+	}
+
+	return c.JSON(fiber.Map{"data": docs})
+}
+
+// GetMitraVerificationAdmin detail verifikasi satu mitra (admin)
+func (h *Handler) GetMitraVerificationAdmin(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+
+	v, err := h.Service.GetVerification(c.Context(), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "mitra tidak ditemukan"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignDocuments(v.Documents)
+
+	return c.JSON(fiber.Map{"data": v})
+}
+
+// ReviewDocument admin approve / reject dokumen
+func (h *Handler) ReviewDocument(c *fiber.Ctx) error {
+	reviewerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	docID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid document id"})
+	}
+
+	var body struct {
+		Decision      string `json:"decision"` // APPROVE | REJECT
+		Comment       string `json:"comment"`
+		LicenseNumber string `json:"license_number"` // opsional, koreksi nomor lisensi
+		ExpiresAt     string `json:"expires_at"`     // opsional, YYYY-MM-DD
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	doc, err := h.Service.ReviewDocument(
+		c.Context(),
+		int64(reviewerID),
+		docID,
+		body.Decision,
+		body.Comment,
+		body.LicenseNumber,
+		body.ExpiresAt,
+	)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignDocument(&doc)
+
+	return c.JSON(fiber.Map{
+		"message": "document reviewed",
+		"data":    doc,
+	})
+}
+
+// END KYC
+
+// GetRequiredDocuments dokumen wajib registrasi mitra per vertical (?job_category_id=)
+func (h *Handler) GetRequiredDocuments(c *fiber.Ctx) error {
+	catID := int64(c.QueryInt("job_category_id"))
+	if catID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "job_category_id wajib diisi"})
+	}
+
+	v := h.Service.Vertical(c.Context(), catID)
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"job_category_id":    catID,
+			"mitra_label":        v.MitraLabel,
+			"required_documents": v.RequiredDocuments,
+			"license_documents":  h.Service.LicenseDocTypes(c.Context()),
+		},
+	})
+}
+
 // START MITRA PROFILE
 func (h *Handler) GetMyMitraProfile(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Get My Mitra Profile belum diimplementasi"})
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	profile, err := h.Service.GetMitraProfile(c.Context(), int64(mitraID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "mitra belum terdaftar"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignProfile(&profile)
+
+	return c.JSON(fiber.Map{"data": profile})
+}
+
+// UpdateMyMitraProfile ubah bio, alamat praktik dan spesialisasi
+func (h *Handler) UpdateMyMitraProfile(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var body models.UpdateMitraProfileRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	profile, err := h.Service.UpdateMitraProfile(c.Context(), int64(mitraID), body)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "mitra belum terdaftar"})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignProfile(&profile)
+
+	return c.JSON(fiber.Map{
+		"message": "profile updated",
+		"data":    profile,
+	})
+}
+
+// UpdateMyMitraPhoto ganti foto profil (multipart field "photo"), foto lama
+// dihapus dari MinIO
+func (h *Handler) UpdateMyMitraPhoto(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	fileHeader, err := c.FormFile("photo")
+	if err != nil || fileHeader == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "photo wajib diisi"})
+	}
+	if fileHeader.Size > maxProfilePhotoBytes {
+		return c.Status(400).JSON(fiber.Map{"error": "ukuran foto maksimal 5MB"})
+	}
+
+	userName := "unknown"
+	if nameVal := c.Locals("nama"); nameVal != nil {
+		userName = nameVal.(string)
+	}
+	userDir := fmt.Sprintf("user-%d-%s/profile", mitraID, helper.SanitizeFileName(userName))
+
+	bucket := mitraBucket()
+	stored, err := helper.UploadFileToMinio(h.MinioClient, bucket, userDir, fileHeader, maxProfilePhotoBytes)
+	if err != nil {
+		return c.Status(uploadErrorStatus(err)).JSON(fiber.Map{"error": fmt.Sprintf("failed to upload photo: %v", err)})
+	}
+
+	oldKeys, err := h.Service.UpdateMitraPhoto(c.Context(), int64(mitraID), stored)
+	if err != nil {
+		// foto baru tidak terpakai
+		h.removeStoredFile(bucket, stored)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "mitra belum terdaftar"})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	for _, key := range oldKeys {
+		if key == stored.Key || key == stored.ThumbKey {
+			continue
+		}
+		if err := helper.RemoveObjectFromMinio(h.MinioClient, bucket, key); err != nil {
+			log.Printf("⚠️ remove old photo %s: %v", key, err)
+		}
+	}
+
+	profile, err := h.Service.GetMitraProfile(c.Context(), int64(mitraID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.presignProfile(&profile)
+
+	return c.JSON(fiber.Map{
+		"message": "photo updated",
+		"data":    profile,
+	})
 }
 
 // END MITRA PROFILE
 
+// START AVAILABILITY MITRA
+func (h *Handler) UpdateAvailability(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var body struct {
+		Online *bool `json:"online"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Online == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "online wajib diisi"})
+	}
+
+	if err := h.Service.SetAvailability(c.Context(), int64(mitraID), *body.Online); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "availability updated",
+		"online":  *body.Online,
+	})
+}
+
+func (h *Handler) GetWorkingHours(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	hours, err := h.Service.GetWorkingHours(c.Context(), int64(mitraID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": hours})
+}
+
+func (h *Handler) UpdateWorkingHours(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var body struct {
+		Hours []models.MitraWorkingHour `json:"hours"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	if err := h.Service.UpdateWorkingHours(c.Context(), int64(mitraID), body.Hours); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "working hours updated"})
+}
+
+// UpdateLocation heartbeat lokasi mitra saat online
+func (h *Handler) UpdateLocation(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var body struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	if err := h.Service.UpdateLocation(c.Context(), int64(mitraID), body.Latitude, body.Longitude); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "location updated"})
+}
+
+// END AVAILABILITY MITRA
+
 // START CUSTOMER SEARCH DOKTER
 func (h *Handler) SearchDoctor(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
@@ -128,6 +505,54 @@ func (h *Handler) SearchDoctor(c *fiber.Ctx) error {
 	})
 }
 
+// START CUSTOMER QUOTE HARGA
+func (h *Handler) CreateQuote(c *fiber.Ctx) error {
+	customerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req QuoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	quoteID, quote, err := h.Service.CreateQuote(c.Context(), int64(customerID), req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"quote_id":   quoteID,
+		"expires_at": quote.ExpiresAt,
+		"data":       quote,
+	})
+}
+
+// START CUSTOMER BOOKING TERJADWAL
+func (h *Handler) CreateBooking(c *fiber.Ctx) error {
+	customerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req BookingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	requestID, err := h.Service.CreateBooking(c.Context(), int64(customerID), req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "booking created",
+		"request_id":   requestID,
+		"scheduled_at": req.ScheduledAt,
+	})
+}
+
 // -------------------------------
 // CANCEL ORDERAN
 // -------------------------------
@@ -195,6 +620,101 @@ func (h *Handler) AcceptOffer(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "offer accepted"})
 }
 
+// START DOKTER REJECT
+func (h *Handler) RejectOffer(c *fiber.Ctx) error {
+	offerID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid offer id"})
+	}
+
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	// body opsional
+	var body struct {
+		ReasonCode string `json:"reason_code"`
+		Note       string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+		}
+	}
+
+	if err := h.Service.RejectOffer(
+		c.Context(),
+		int64(offerID),
+		int64(mitraID),
+		body.ReasonCode,
+		body.Note,
+	); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "offer rejected"})
+}
+
+// CancelServiceOrderCustomer customer membatalkan order setelah diterima dokter
+func (h *Handler) CancelServiceOrderCustomer(c *fiber.Ctx) error {
+	return h.cancelServiceOrder(c, CancelledByCustomer)
+}
+
+// CancelServiceOrderMitra dokter membatalkan order yang sudah diterima
+func (h *Handler) CancelServiceOrderMitra(c *fiber.Ctx) error {
+	return h.cancelServiceOrder(c, CancelledByMitra)
+}
+
+func (h *Handler) cancelServiceOrder(c *fiber.Ctx, cancelledBy string) error {
+	orderID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	userID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var body struct {
+		ReasonCode string `json:"reason_code"`
+		Note       string `json:"note"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	result, err := h.Service.CancelServiceOrder(
+		c.Context(),
+		int64(orderID),
+		int64(userID),
+		cancelledBy,
+		body.ReasonCode,
+		body.Note,
+	)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "order cancelled",
+		"data":    result,
+	})
+}
+
+// GetCancelReasonsCustomer katalog alasan batal customer (?job_category_id=)
+func (h *Handler) GetCancelReasonsCustomer(c *fiber.Ctx) error {
+	catID := int64(c.QueryInt("job_category_id"))
+	return c.JSON(fiber.Map{"data": h.Service.GetCancelReasons(c.Context(), CancelledByCustomer, catID)})
+}
+
+// GetCancelReasonsMitra katalog alasan batal mitra (?job_category_id=)
+func (h *Handler) GetCancelReasonsMitra(c *fiber.Ctx) error {
+	catID := int64(c.QueryInt("job_category_id"))
+	return c.JSON(fiber.Map{"data": h.Service.GetCancelReasons(c.Context(), CancelledByMitra, catID)})
+}
+
 // START DETAIL DOKTER DI CUSTOMER SETELAH SERVICE ORDER
 func (h *Handler) GetMyServiceOrders(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
@@ -280,37 +800,49 @@ func (h *Handler) CompleteOrder(c *fiber.Ctx) error {
 	// 📂 folder MinIO per order
 	userDir := fmt.Sprintf("orders/%d", orderID)
 
-	// ambil multiple photos
+	// ambil multiple photos / documents / signature
 	form, err := c.MultipartForm()
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid multipart"})
 	}
 
-	var photoPaths []string
-	if files := form.File["photos"]; len(files) > 0 {
-		for _, file := range files {
-			path, err := helper.UploadFileToMinio(
+	var attachments []OrderAttachmentFile
+	cleanup := func() {
+		for _, att := range attachments {
+			h.removeStoredFile(orderAttachmentBucket(), att.File)
+		}
+	}
+
+	for _, field := range []struct{ name, attachmentType string }{
+		{"photos", AttachmentTypePhoto},
+		{"documents", AttachmentTypeDocument},
+		{"signature", AttachmentTypeSignature},
+	} {
+		for _, file := range form.File[field.name] {
+			stored, err := helper.UploadFileToMinio(
 				h.MinioClient,
-				"uploads",
+				orderAttachmentBucket(),
 				userDir,
 				file,
+				maxUploadBytes,
 			)
 			if err != nil {
-				return c.Status(500).JSON(fiber.Map{
-					"error": "upload photo failed",
+				cleanup()
+				return c.Status(uploadErrorStatus(err)).JSON(fiber.Map{
+					"error": fmt.Sprintf("upload %s failed: %v", field.attachmentType, err),
 				})
 			}
-			photoPaths = append(photoPaths, path)
+			attachments = append(attachments, OrderAttachmentFile{Type: field.attachmentType, File: stored})
 		}
 	}
 
-	req.Attachments = photoPaths
-
 	if err := h.Service.CompleteOrder(
 		c.Context(),
 		mitraID,
 		&req,
+		attachments,
 	); err != nil {
+		cleanup()
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
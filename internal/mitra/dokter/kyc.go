@@ -0,0 +1,397 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/helper"
+)
+
+// Status review dokumen (mitra_documents.status)
+const (
+	DocStatusPending  = "PENDING"
+	DocStatusApproved = "APPROVED"
+	DocStatusRejected = "REJECTED"
+)
+
+// Status verifikasi mitra (mitra_details.verification_status)
+const (
+	VerificationPending   = "PENDING"   // ada dokumen belum direview / belum lengkap
+	VerificationVerified  = "VERIFIED"  // semua dokumen wajib disetujui, boleh menerima order
+	VerificationRejected  = "REJECTED"  // ada dokumen ditolak, menunggu upload ulang
+	VerificationSuspended = "SUSPENDED" // lisensi kedaluwarsa, menunggu perpanjangan disetujui
+)
+
+// Keputusan review admin
+const (
+	ReviewApprove = "APPROVE"
+	ReviewReject  = "REJECT"
+)
+
+var docTypeLabels = map[string]string{
+	"foto":            "Foto profil",
+	"foto_ktp":        "Foto KTP",
+	"foto_selfie_ktp": "Foto selfie dengan KTP",
+	"str":             "STR",
+	"sip":             "SIP",
+}
+
+func docTypeLabel(docType string) string {
+	if l, ok := docTypeLabels[docType]; ok {
+		return l
+	}
+	return strings.ToUpper(docType)
+}
+
+// GetDocumentReviewQueue antrian review dokumen (default PENDING)
+func (s *Service) GetDocumentReviewQueue(ctx context.Context, status string, limit, offset int) ([]models.MitraDocumentReview, error) {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	switch status {
+	case "":
+		status = DocStatusPending
+	case DocStatusPending, DocStatusApproved, DocStatusRejected:
+	default:
+		return nil, errors.New("status harus PENDING, APPROVED atau REJECTED")
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.Repo.GetDocumentReviewQueue(ctx, status, limit, offset)
+}
+
+// GetVerification status verifikasi mitra beserta dokumen aktif
+func (s *Service) GetVerification(ctx context.Context, userID int64) (models.MitraVerification, error) {
+	row, err := s.Repo.GetMitraVerification(ctx, userID)
+	if err != nil {
+		return models.MitraVerification{}, err
+	}
+
+	docs, err := s.Repo.GetCurrentDocuments(ctx, userID)
+	if err != nil {
+		return models.MitraVerification{}, err
+	}
+
+	required := s.Vertical(ctx, row.JobCategoryID).RequiredDocuments
+
+	return models.MitraVerification{
+		UserID:             userID,
+		JobCategoryID:      row.JobCategoryID,
+		VerificationStatus: row.VerificationStatus,
+		VerifiedAt:         row.VerifiedAt,
+		RequiredDocuments:  required,
+		MissingDocuments:   missingDocuments(required, docs),
+		Documents:          docs,
+	}, nil
+}
+
+// ReviewDocument admin setujui / tolak satu dokumen, lalu hitung ulang
+// status verifikasi mitra. Penolakan wajib disertai komentar.
+func (s *Service) ReviewDocument(
+	ctx context.Context,
+	reviewerID int64,
+	docID int64,
+	decision string,
+	comment string,
+	licenseNumber string,
+	expiresAt string,
+) (models.MitraDocument, error) {
+
+	comment = strings.TrimSpace(comment)
+
+	expiry, err := parseLicenseDate(expiresAt)
+	if err != nil {
+		return models.MitraDocument{}, fmt.Errorf("expires_at: %v", err)
+	}
+	license := LicenseInfo{Number: strings.TrimSpace(licenseNumber), ExpiresAt: expiry}
+
+	var status string
+	switch strings.ToUpper(strings.TrimSpace(decision)) {
+	case ReviewApprove:
+		status = DocStatusApproved
+	case ReviewReject:
+		if comment == "" {
+			return models.MitraDocument{}, errors.New("comment wajib diisi untuk penolakan")
+		}
+		status = DocStatusRejected
+	default:
+		return models.MitraDocument{}, errors.New("decision harus APPROVE atau REJECT")
+	}
+
+	// lisensi hanya boleh disetujui jika nomor & masa berlaku sudah lengkap
+	if status == DocStatusApproved {
+		current, err := s.Repo.GetDocument(ctx, docID)
+		if err != nil {
+			return models.MitraDocument{}, err
+		}
+		if containsString(s.LicenseDocTypes(ctx), current.DocType) {
+			if license.Number == "" && current.LicenseNumber == nil {
+				return models.MitraDocument{}, errors.New("license_number wajib diisi untuk dokumen lisensi")
+			}
+			if license.ExpiresAt == nil {
+				license.ExpiresAt = current.ExpiresAt
+			}
+			if license.ExpiresAt == nil {
+				return models.MitraDocument{}, errors.New("expires_at wajib diisi untuk dokumen lisensi")
+			}
+			if licenseExpired(*license.ExpiresAt) {
+				return models.MitraDocument{}, errors.New("lisensi sudah kedaluwarsa")
+			}
+		}
+	}
+
+	doc, err := s.Repo.ReviewDocument(ctx, docID, reviewerID, status, comment, license)
+	if err != nil {
+		return doc, err
+	}
+
+	log.Printf("🪪 Document %d (%s) of mitra %d %s by %d", doc.ID, doc.DocType, doc.UserID, status, reviewerID)
+	s.notifyDocumentReviewed(doc)
+
+	if _, err := s.refreshVerification(ctx, doc.UserID); err != nil {
+		log.Printf("❌ refresh verification mitra %d: %v", doc.UserID, err)
+	}
+
+	return doc, nil
+}
+
+// ReuploadDocument mitra upload ulang dokumen yang ditolak / belum ada,
+// atau perpanjangan lisensi yang sudah / hampir kedaluwarsa
+func (s *Service) ReuploadDocument(
+	ctx context.Context,
+	userID int64,
+	docType string,
+	file helper.StoredFile,
+	license LicenseInfo,
+) (models.MitraVerification, error) {
+	if err := s.checkReupload(ctx, userID, docType); err != nil {
+		return models.MitraVerification{}, err
+	}
+
+	if err := s.Repo.CreateDocument(int(userID), docType, file, license); err != nil {
+		return models.MitraVerification{}, err
+	}
+
+	if _, err := s.refreshVerification(ctx, userID); err != nil {
+		return models.MitraVerification{}, err
+	}
+
+	return s.GetVerification(ctx, userID)
+}
+
+// checkReupload dokumen boleh diupload jika diperlukan vertical mitra dan
+// tidak sedang direview / sudah disetujui (kecuali perpanjangan lisensi)
+func (s *Service) checkReupload(ctx context.Context, userID int64, docType string) error {
+	row, err := s.Repo.GetMitraVerification(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	required := s.Vertical(ctx, row.JobCategoryID).RequiredDocuments
+	if !containsString(required, docType) {
+		return fmt.Errorf("doc_type %s tidak diperlukan untuk layanan ini", docType)
+	}
+
+	docs, err := s.Repo.GetCurrentDocuments(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		if d.DocType != docType {
+			continue
+		}
+		switch d.Status {
+		case DocStatusPending:
+			return errors.New("dokumen sedang direview")
+		case DocStatusApproved:
+			if !s.licenseRenewable(ctx, d) {
+				return errors.New("dokumen sudah disetujui")
+			}
+		}
+	}
+
+	return nil
+}
+
+// refreshVerification hitung status verifikasi dari dokumen aktif:
+// semua dokumen wajib APPROVED & masih berlaku → VERIFIED, ada REJECTED →
+// REJECTED, lisensi kedaluwarsa → SUSPENDED, selain itu PENDING.
+// Notifikasi dikirim jika status berubah.
+func (s *Service) refreshVerification(ctx context.Context, userID int64) (string, error) {
+	row, err := s.Repo.GetMitraVerification(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	docs, err := s.Repo.GetCurrentDocuments(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	required := s.Vertical(ctx, row.JobCategoryID).RequiredDocuments
+	status := computeVerification(required, s.LicenseDocTypes(ctx), docs)
+
+	changed, err := s.Repo.SetVerificationStatus(ctx, userID, status)
+	if err != nil {
+		return "", err
+	}
+	if changed {
+		log.Printf("🪪 Mitra %d verification %s → %s", userID, row.VerificationStatus, status)
+		s.notifyVerificationChanged(userID, status)
+	}
+
+	return status, nil
+}
+
+func computeVerification(required, licenseTypes []string, docs []models.MitraDocument) string {
+	// per doc_type bisa ada dua dokumen aktif: lisensi APPROVED lama + perpanjangan PENDING
+	byType := make(map[string][]models.MitraDocument, len(docs))
+	for _, d := range docs {
+		byType[d.DocType] = append(byType[d.DocType], d)
+	}
+
+	approved, rejected, suspended := 0, false, false
+	for _, t := range required {
+		switch docTypeVerification(byType[t], containsString(licenseTypes, t)) {
+		case VerificationVerified:
+			approved++
+		case VerificationRejected:
+			rejected = true
+		case VerificationSuspended:
+			suspended = true
+		}
+	}
+
+	switch {
+	case approved == len(required):
+		return VerificationVerified
+	case rejected:
+		return VerificationRejected
+	case suspended:
+		return VerificationSuspended
+	}
+	return VerificationPending
+}
+
+// docTypeVerification status satu jenis dokumen. Lisensi APPROVED tanpa
+// tanggal berlaku (data sebelum masa berlaku dicatat) tidak dianggap
+// berlaku selamanya: mitra PENDING sampai lisensi dengan masa berlaku
+// diupload ulang dan disetujui.
+func docTypeVerification(docs []models.MitraDocument, license bool) string {
+	status := VerificationPending
+	for _, d := range docs {
+		switch d.Status {
+		case DocStatusApproved:
+			if d.ExpiresAt == nil {
+				if !license {
+					return VerificationVerified
+				}
+				continue
+			}
+			if !licenseExpired(*d.ExpiresAt) {
+				return VerificationVerified
+			}
+			status = VerificationSuspended
+		case DocStatusRejected:
+			if status == VerificationPending {
+				status = VerificationRejected
+			}
+		}
+	}
+	return status
+}
+
+func missingDocuments(required []string, docs []models.MitraDocument) []string {
+	missing := []string{}
+	for _, t := range required {
+		found := false
+		for _, d := range docs {
+			if d.DocType == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyDocumentReviewed FCM + websocket ke mitra untuk tiap keputusan dokumen
+func (s *Service) notifyDocumentReviewed(doc models.MitraDocument) {
+	label := docTypeLabel(doc.DocType)
+
+	title := "Dokumen Disetujui ✅"
+	body := fmt.Sprintf("%s kamu sudah disetujui", label)
+	if doc.Status == DocStatusRejected {
+		title = "Dokumen Ditolak ❌"
+		body = fmt.Sprintf("%s kamu ditolak", label)
+		if doc.ReviewComment != nil {
+			body += ": " + *doc.ReviewComment
+		}
+		body += ". Silakan upload ulang"
+	}
+
+	s.pushToUser(doc.UserID, title, body, map[string]string{
+		"type":     "KYC_DOCUMENT_REVIEWED",
+		"doc_id":   strconv.FormatInt(doc.ID, 10),
+		"doc_type": doc.DocType,
+		"status":   doc.Status,
+	})
+
+	s.Users.Send(doc.UserID, map[string]interface{}{
+		"event":          "kyc_document_reviewed",
+		"doc_id":         doc.ID,
+		"doc_type":       doc.DocType,
+		"status":         doc.Status,
+		"review_comment": doc.ReviewComment,
+	})
+}
+
+// notifyVerificationChanged FCM + websocket saat status verifikasi berubah
+func (s *Service) notifyVerificationChanged(userID int64, status string) {
+	var title, body string
+	switch status {
+	case VerificationVerified:
+		title = "Akun Terverifikasi 🎉"
+		body = "Semua dokumen kamu sudah disetujui. Kamu sekarang bisa online dan menerima order"
+	case VerificationRejected:
+		title = "Verifikasi Belum Lolos"
+		body = "Ada dokumen yang ditolak. Cek detailnya dan upload ulang dokumen kamu"
+	case VerificationSuspended:
+		title = "Akun Ditangguhkan ⚠️"
+		body = "Lisensi kamu sudah kedaluwarsa. Upload STR / SIP terbaru agar bisa menerima order lagi"
+	default:
+		title = "Dokumen Sedang Direview"
+		body = "Dokumen kamu sedang kami review"
+	}
+
+	s.pushToUser(userID, title, body, map[string]string{
+		"type":   "KYC_VERIFICATION_STATUS",
+		"status": status,
+	})
+
+	s.Users.Send(userID, map[string]interface{}{
+		"event":               "kyc_verification_status",
+		"verification_status": status,
+	})
+}
@@ -0,0 +1,252 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/utils"
+)
+
+const licenseDateLayout = "2006-01-02"
+
+// default jika global parameter belum diisi
+var (
+	defaultLicenseDocTypes      = []string{"str", "sip"}
+	defaultLicenseReminderDays  = []int{60, 30, 7}
+	licenseExpiryWorkerInterval = 1 * time.Hour
+)
+
+// LicenseDocTypes jenis dokumen yang wajib punya nomor & tanggal kedaluwarsa
+// (LICENSE_DOC_TYPES, dipisah koma)
+func (s *Service) LicenseDocTypes(ctx context.Context) []string {
+	val, err := s.Repo.GetGlobalParameter(ctx, "LICENSE_DOC_TYPES")
+	if err != nil || strings.TrimSpace(val) == "" {
+		return defaultLicenseDocTypes
+	}
+
+	var types []string
+	for _, t := range strings.Split(val, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// licenseReminderDays ambang reminder H-N, urut dari yang terkecil
+// (LICENSE_EXPIRY_REMINDER_DAYS, dipisah koma)
+func (s *Service) licenseReminderDays(ctx context.Context) []int {
+	days := defaultLicenseReminderDays
+	if val, err := s.Repo.GetGlobalParameter(ctx, "LICENSE_EXPIRY_REMINDER_DAYS"); err == nil && strings.TrimSpace(val) != "" {
+		var parsed []int
+		for _, p := range strings.Split(val, ",") {
+			if n, err := strconv.Atoi(strings.TrimSpace(p)); err == nil && n > 0 {
+				parsed = append(parsed, n)
+			}
+		}
+		if len(parsed) > 0 {
+			days = parsed
+		}
+	}
+
+	sorted := append([]int(nil), days...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+// ParseLicense validasi nomor & tanggal kedaluwarsa (YYYY-MM-DD) untuk
+// dokumen lisensi. Dokumen non-lisensi mengembalikan LicenseInfo kosong.
+func (s *Service) ParseLicense(ctx context.Context, docType, number, expiresAt string) (LicenseInfo, error) {
+	if !containsString(s.LicenseDocTypes(ctx), docType) {
+		return LicenseInfo{}, nil
+	}
+
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return LicenseInfo{}, fmt.Errorf("nomor %s wajib diisi", docTypeLabel(docType))
+	}
+	if len(number) > 60 {
+		return LicenseInfo{}, fmt.Errorf("nomor %s maksimal 60 karakter", docTypeLabel(docType))
+	}
+
+	expiry, err := parseLicenseDate(expiresAt)
+	if err != nil {
+		return LicenseInfo{}, fmt.Errorf("tanggal berlaku %s: %v", docTypeLabel(docType), err)
+	}
+	if expiry == nil {
+		return LicenseInfo{}, fmt.Errorf("tanggal berlaku %s wajib diisi", docTypeLabel(docType))
+	}
+	if licenseExpired(*expiry) {
+		return LicenseInfo{}, fmt.Errorf("%s sudah kedaluwarsa", docTypeLabel(docType))
+	}
+
+	return LicenseInfo{Number: number, ExpiresAt: expiry}, nil
+}
+
+// parseLicenseDate format YYYY-MM-DD, string kosong → nil
+func parseLicenseDate(val string) (*time.Time, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(licenseDateLayout, val)
+	if err != nil {
+		return nil, errors.New("format tanggal harus YYYY-MM-DD")
+	}
+	return &t, nil
+}
+
+// licenseToday tanggal hari ini (tanpa jam) untuk dibandingkan dengan kolom DATE
+func licenseToday() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func licenseExpired(expiresAt time.Time) bool {
+	y, m, d := expiresAt.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Before(licenseToday())
+}
+
+// licenseRenewable dokumen APPROVED boleh diperpanjang jika sudah / hampir
+// kedaluwarsa (masuk ambang reminder terbesar), atau lisensi lama yang
+// belum punya tanggal berlaku
+func (s *Service) licenseRenewable(ctx context.Context, doc models.MitraDocument) bool {
+	if doc.ExpiresAt == nil {
+		return containsString(s.LicenseDocTypes(ctx), doc.DocType)
+	}
+	days := s.licenseReminderDays(ctx)
+	window := days[len(days)-1]
+	return doc.ExpiresAt.Before(licenseToday().AddDate(0, 0, window+1))
+}
+
+// RunLicenseExpiryWorker kirim reminder perpanjangan STR / SIP (H-60/30/7)
+// dan suspend mitra yang lisensinya sudah kedaluwarsa. Klaim reminder
+// idempoten sehingga aman dijalankan tiap jam.
+func (s *Service) RunLicenseExpiryWorker(ctx context.Context) {
+	ticker := time.NewTicker(licenseExpiryWorkerInterval)
+	defer ticker.Stop()
+
+	log.Println("👷 License Expiry Worker started")
+
+	s.checkLicenseExpiry(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👷 License Expiry Worker stopped")
+			return
+		case <-ticker.C:
+			s.checkLicenseExpiry(ctx)
+		}
+	}
+}
+
+func (s *Service) checkLicenseExpiry(ctx context.Context) {
+	// 1️⃣ reminder, dari ambang terkecil supaya dokumen hanya dapat satu reminder
+	for _, days := range s.licenseReminderDays(ctx) {
+		reminders, err := s.Repo.ClaimLicenseReminders(ctx, days)
+		if err != nil {
+			log.Printf("❌ license reminder H-%d: %v", days, err)
+			continue
+		}
+		for _, r := range reminders {
+			log.Printf("📜 License %s of mitra %d expires in %d day(s)", r.DocType, r.UserID, r.DaysLeft)
+			s.notifyLicenseExpiring(r)
+		}
+	}
+
+	// 2️⃣ suspend mitra dengan lisensi kedaluwarsa
+	ids, err := s.Repo.GetMitraWithExpiredLicenses(ctx)
+	if err != nil {
+		log.Println("❌ license expiry worker error:", err)
+		return
+	}
+	for _, id := range ids {
+		if _, err := s.refreshVerification(ctx, id); err != nil {
+			log.Printf("❌ suspend mitra %d: %v", id, err)
+		}
+	}
+
+	// 3️⃣ mitra VERIFIED dengan lisensi lama tanpa tanggal berlaku → PENDING
+	//    sampai lisensi + masa berlaku diupload ulang
+	licenseTypes := s.LicenseDocTypes(ctx)
+	missing, err := s.Repo.GetMitraWithMissingLicenseExpiry(ctx, licenseTypes)
+	if err != nil {
+		log.Println("❌ license expiry worker error:", err)
+		return
+	}
+	for _, m := range missing {
+		status, err := s.refreshVerification(ctx, m.UserID)
+		if err != nil {
+			log.Printf("❌ refresh verification mitra %d: %v", m.UserID, err)
+			continue
+		}
+		if status != VerificationVerified {
+			log.Printf("📜 Mitra %d: %s has no expiry date, verification %s", m.UserID, m.DocType, status)
+			s.notifyLicenseExpiryMissing(m.UserID, m.DocType)
+		}
+	}
+}
+
+// notifyLicenseExpiryMissing minta mitra upload ulang lisensi beserta masa berlaku
+func (s *Service) notifyLicenseExpiryMissing(userID int64, docType string) {
+	label := docTypeLabel(docType)
+	s.pushToUser(
+		userID,
+		fmt.Sprintf("Lengkapi Masa Berlaku %s", label),
+		fmt.Sprintf("Upload ulang %s beserta nomor dan tanggal berlakunya agar tetap bisa menerima order", label),
+		map[string]string{
+			"type":     "LICENSE_EXPIRY_MISSING",
+			"doc_type": docType,
+		},
+	)
+}
+
+// notifyLicenseExpiring FCM + email reminder perpanjangan lisensi
+func (s *Service) notifyLicenseExpiring(r LicenseReminder) {
+	label := docTypeLabel(r.DocType)
+	expiry := r.ExpiresAt.Format("02-01-2006")
+
+	title := fmt.Sprintf("%s Segera Berakhir ⏳", label)
+	body := fmt.Sprintf(
+		"%s kamu berakhir dalam %d hari (%s). Upload %s terbaru agar tetap bisa menerima order",
+		label, r.DaysLeft, expiry, label,
+	)
+
+	s.pushToUser(r.UserID, title, body, map[string]string{
+		"type":       "LICENSE_EXPIRING",
+		"doc_id":     strconv.FormatInt(r.DocID, 10),
+		"doc_type":   r.DocType,
+		"expires_at": r.ExpiresAt.Format(licenseDateLayout),
+		"days_left":  strconv.Itoa(r.DaysLeft),
+	})
+
+	if r.Email == "" {
+		return
+	}
+
+	number := "-"
+	if r.LicenseNumber != nil {
+		number = *r.LicenseNumber
+	}
+
+	go func() {
+		html := fmt.Sprintf(`
+		<h2>%s Segera Berakhir</h2>
+		<p>Halo %s,</p>
+		<p>%s kamu dengan nomor <b>%s</b> akan berakhir pada <b>%s</b> (%d hari lagi).</p>
+		<p>Upload %s terbaru melalui aplikasi sebelum tanggal tersebut. Akun yang lisensinya kedaluwarsa otomatis tidak dapat menerima order sampai dokumen baru disetujui.</p>
+	`, label, r.Nama, label, number, expiry, r.DaysLeft, label)
+
+		if err := utils.SendEmail(r.Email, title, html); err != nil {
+			log.Printf("❌ license reminder email mitra %d: %v", r.UserID, err)
+		}
+	}()
+}
@@ -0,0 +1,43 @@
+package dokter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"teka-api/internal/realtime/firebase"
+)
+
+// pushToUser kirim FCM async ke semua device user + simpan log per token
+func (s *Service) pushToUser(userID int64, title, body string, data map[string]string) {
+	go func() {
+		fcmCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		tokens, err := s.Repo.GetFCMTokensByUserID(fcmCtx, userID)
+		if err != nil {
+			log.Println("GetFCMTokens error:", err)
+			return
+		}
+		if len(tokens) == 0 {
+			log.Println("No FCM token for user:", userID)
+			return
+		}
+
+		results := firebase.SendFCMToTokens(fcmCtx, tokens, title, body, data)
+
+		for token, err := range results {
+			status := "SENT"
+			var errStr *string
+			if err != nil {
+				status = "FAILED"
+				e := err.Error()
+				errStr = &e
+			}
+
+			if dbErr := s.Repo.LogFCMResult(userID, token, status, errStr); dbErr != nil {
+				log.Println("❌ Failed to log FCM result:", dbErr)
+			}
+		}
+	}()
+}
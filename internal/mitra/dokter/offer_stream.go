@@ -0,0 +1,68 @@
+package dokter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"teka-api/internal/models"
+)
+
+// Event offer untuk mitra (websocket /ws/dokter/offers)
+const (
+	OfferEventSnapshot  = "offer_snapshot"  // offer aktif saat client connect (bisa null)
+	OfferEventNew       = "offer_new"       // offer baru masuk
+	OfferEventExpired   = "offer_expired"   // waktu respon habis
+	OfferEventWithdrawn = "offer_withdrawn" // diambil dokter lain / dibatalkan customer
+)
+
+// offerMessage payload offer + waktu server supaya timer di app akurat
+// walau jam device tidak sinkron
+func offerMessage(event string, offer *models.CurrentOffer) map[string]interface{} {
+	now := time.Now()
+	msg := map[string]interface{}{
+		"event":       event,
+		"offer":       offer,
+		"server_time": now,
+	}
+
+	if offer != nil && offer.ExpiredAt != nil {
+		remaining := int(offer.ExpiredAt.Sub(now).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		msg["remaining_seconds"] = remaining
+	}
+
+	return msg
+}
+
+// pushOfferToMitra kirim detail offer baru ke websocket mitra
+func (s *Service) pushOfferToMitra(target OfferTarget) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		offer, err := s.Repo.GetOfferDetailForMitra(ctx, target.OfferID, target.MitraID)
+		if err != nil {
+			// offer sudah tidak waiting (langsung diambil / dibatalkan)
+			log.Printf("offer ws: offer %d for mitra %d: %v", target.OfferID, target.MitraID, err)
+			return
+		}
+
+		s.Offers.Send(target.MitraID, offerMessage(OfferEventNew, offer))
+	}()
+}
+
+// publishOfferEvent event offer tanpa detail (expired / withdrawn)
+func (s *Service) publishOfferEvent(mitraID int64, event string, data map[string]interface{}) {
+	msg := map[string]interface{}{
+		"event":       event,
+		"server_time": time.Now(),
+	}
+	for k, v := range data {
+		msg[k] = v
+	}
+
+	s.Offers.Send(mitraID, msg)
+}
@@ -0,0 +1,130 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/helper"
+
+	"gorm.io/gorm"
+)
+
+// Tipe lampiran order
+const (
+	AttachmentTypePhoto     = "photo"
+	AttachmentTypeDocument  = "document"
+	AttachmentTypeSignature = "signature"
+	AttachmentTypeChat      = "chat" // lampiran pesan chat, tidak masuk bukti layanan
+)
+
+// content type yang diterima per tipe lampiran bukti layanan
+var attachmentContentTypes = map[string]map[string]bool{
+	AttachmentTypePhoto: {
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/webp": true,
+	},
+	AttachmentTypeDocument: {
+		"image/jpeg":      true,
+		"image/png":       true,
+		"image/webp":      true,
+		"application/pdf": true,
+	},
+	AttachmentTypeSignature: {
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/webp": true,
+	},
+}
+
+// normalizeAttachmentType tipe lampiran bukti layanan, kosong → photo
+// (client lama hanya kirim foto)
+func normalizeAttachmentType(t string) (string, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if t == "" {
+		return AttachmentTypePhoto, nil
+	}
+	if _, ok := attachmentContentTypes[t]; !ok {
+		return "", errors.New("type harus photo, document atau signature")
+	}
+	return t, nil
+}
+
+// checkAttachmentContentType isi file harus sesuai tipe lampiran
+func checkAttachmentContentType(attachmentType, contentType string) error {
+	if attachmentContentTypes[attachmentType][contentType] {
+		return nil
+	}
+	if attachmentType == AttachmentTypeDocument {
+		return errors.New("dokumen harus PDF, JPEG, PNG atau WEBP")
+	}
+	return fmt.Errorf("%s harus JPEG, PNG atau WEBP", attachmentType)
+}
+
+// ListOrderAttachments bukti layanan untuk customer / mitra order atau admin
+func (s *Service) ListOrderAttachments(ctx context.Context, viewerID, orderID int64) ([]models.OrderAttachment, error) {
+	order, err := s.Repo.GetOrderParticipants(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.CustomerID != viewerID && order.MitraID != viewerID && !s.IsAdmin(ctx, viewerID) {
+		return nil, ErrFileForbidden
+	}
+
+	list, err := s.Repo.GetOrderAttachments(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []models.OrderAttachment{}
+	}
+	return list, nil
+}
+
+// AddOrderAttachment mitra tambah bukti layanan selama status ARRIVED
+func (s *Service) AddOrderAttachment(
+	ctx context.Context,
+	mitraID, orderID int64,
+	attachmentType string,
+	file helper.StoredFile,
+) (models.OrderAttachment, error) {
+
+	if err := checkAttachmentContentType(attachmentType, file.ContentType); err != nil {
+		return models.OrderAttachment{}, err
+	}
+
+	order, err := s.Repo.GetOrderParticipants(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.OrderAttachment{}, errors.New("order tidak ditemukan")
+		}
+		return models.OrderAttachment{}, err
+	}
+	if order.MitraID != mitraID {
+		return models.OrderAttachment{}, ErrFileForbidden
+	}
+
+	att, err := s.Repo.AddOrderAttachment(ctx, orderID, mitraID, attachmentType, file)
+	if err != nil {
+		return models.OrderAttachment{}, err
+	}
+
+	log.Printf("📎 Order %d: %s attachment %d added by mitra %d", orderID, attachmentType, att.ID, mitraID)
+	s.notifyOrderAttachment(order.CustomerID, orderID, att.ID, attachmentType)
+
+	return att, nil
+}
+
+// notifyOrderAttachment kabari customer ada bukti layanan baru
+func (s *Service) notifyOrderAttachment(customerID, orderID, attachmentID int64, attachmentType string) {
+	s.Users.Send(customerID, map[string]interface{}{
+		"event":         "order_attachment_added",
+		"order_id":      orderID,
+		"attachment_id": attachmentID,
+		"type":          attachmentType,
+	})
+}
@@ -0,0 +1,163 @@
+package dokter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/helper"
+)
+
+// batas field profil mitra
+const (
+	maxBioLength         = 1000
+	maxAddressLength     = 500
+	maxSpecialities      = 10
+	maxSpecialityLength  = 50
+	maxProfilePhotoBytes = 5 * 1024 * 1024
+)
+
+// content type foto profil yang diterima
+var profilePhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// GetMitraProfile profil lengkap mitra: kategori, verifikasi, ketersediaan,
+// rating, jumlah order selesai dan dokumen aktif
+func (s *Service) GetMitraProfile(ctx context.Context, userID int64) (models.MitraProfile, error) {
+	row, err := s.Repo.GetMitraProfile(ctx, userID)
+	if err != nil {
+		return models.MitraProfile{}, err
+	}
+
+	rating, err := s.Repo.GetMitraRatingSummary(ctx, userID)
+	if err != nil {
+		return models.MitraProfile{}, err
+	}
+
+	docs, err := s.Repo.GetCurrentDocuments(ctx, userID)
+	if err != nil {
+		return models.MitraProfile{}, err
+	}
+	if docs == nil {
+		docs = []models.MitraDocument{}
+	}
+
+	specialities := []string{}
+	if row.Specialities != "" {
+		if err := json.Unmarshal([]byte(row.Specialities), &specialities); err != nil {
+			log.Printf("⚠️ mitra %d: invalid specialities: %v", userID, err)
+			specialities = []string{}
+		}
+	}
+
+	return models.MitraProfile{
+		UserID:             row.UserID,
+		Nama:               row.Nama,
+		Email:              row.Email,
+		Phone:              row.Phone,
+		JobCategoryID:      row.JobCategoryID,
+		JobCategoryName:    row.JobCategoryName,
+		JobSubCategoryID:   row.JobSubCategoryID,
+		JobSubCategoryName: row.JobSubCategoryName,
+		MitraLabel:         s.Vertical(ctx, row.JobCategoryID).MitraLabel,
+		Bio:                row.Bio,
+		PhotoKey:           row.PhotoKey,
+		PhotoThumbKey:      row.PhotoThumbKey,
+		PracticeAddress:    row.PracticeAddress,
+		Specialities:       specialities,
+		VerificationStatus: row.VerificationStatus,
+		VerifiedAt:         row.VerifiedAt,
+		Online:             row.AvailabilityStatusID == AvailabilityOnline,
+		Rating:             rating,
+		CompletedOrders:    row.CompletedOrders,
+		Documents:          docs,
+	}, nil
+}
+
+// UpdateMitraProfile ubah bio, alamat praktik dan spesialisasi mitra
+func (s *Service) UpdateMitraProfile(
+	ctx context.Context,
+	userID int64,
+	req models.UpdateMitraProfileRequest,
+) (models.MitraProfile, error) {
+
+	if req.Bio == nil && req.PracticeAddress == nil && req.Specialities == nil {
+		return models.MitraProfile{}, errors.New("tidak ada field yang diubah")
+	}
+
+	var bio, address, specialities *string
+
+	if req.Bio != nil {
+		v := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(v) > maxBioLength {
+			return models.MitraProfile{}, fmt.Errorf("bio maksimal %d karakter", maxBioLength)
+		}
+		bio = &v
+	}
+
+	if req.PracticeAddress != nil {
+		v := strings.TrimSpace(*req.PracticeAddress)
+		if utf8.RuneCountInString(v) > maxAddressLength {
+			return models.MitraProfile{}, fmt.Errorf("practice_address maksimal %d karakter", maxAddressLength)
+		}
+		address = &v
+	}
+
+	if req.Specialities != nil {
+		list, err := normalizeSpecialities(*req.Specialities)
+		if err != nil {
+			return models.MitraProfile{}, err
+		}
+		b, _ := json.Marshal(list)
+		v := string(b)
+		specialities = &v
+	}
+
+	if err := s.Repo.UpdateMitraProfile(ctx, userID, bio, address, specialities); err != nil {
+		return models.MitraProfile{}, err
+	}
+
+	return s.GetMitraProfile(ctx, userID)
+}
+
+// normalizeSpecialities trim, buang duplikat (case-insensitive) dan batasi jumlah
+func normalizeSpecialities(in []string) ([]string, error) {
+	out := []string{}
+	seen := make(map[string]bool, len(in))
+	for _, sp := range in {
+		sp = strings.TrimSpace(sp)
+		if sp == "" {
+			continue
+		}
+		if utf8.RuneCountInString(sp) > maxSpecialityLength {
+			return nil, fmt.Errorf("spesialisasi maksimal %d karakter", maxSpecialityLength)
+		}
+		key := strings.ToLower(sp)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, sp)
+	}
+	if len(out) > maxSpecialities {
+		return nil, fmt.Errorf("maksimal %d spesialisasi", maxSpecialities)
+	}
+	return out, nil
+}
+
+// UpdateMitraPhoto simpan foto profil baru, return object key foto &
+// thumbnail lama supaya bisa dihapus dari MinIO
+func (s *Service) UpdateMitraPhoto(ctx context.Context, userID int64, file helper.StoredFile) ([]string, error) {
+	if !profilePhotoTypes[file.ContentType] {
+		return nil, errors.New("foto harus JPEG, PNG atau WEBP")
+	}
+	return s.Repo.UpdateMitraPhoto(ctx, userID, file)
+}
@@ -0,0 +1,187 @@
+package dokter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/internal/voucher"
+
+	"gorm.io/gorm"
+)
+
+// CreateQuote hitung rincian harga dari job_tariffs + global parameter +
+// voucher, simpan, lalu kembalikan quote_id bertanda tangan
+func (s *Service) CreateQuote(
+	ctx context.Context,
+	customerID int64,
+	req QuoteRequest,
+) (string, *models.CustomerQuote, error) {
+
+	if req.JobCategoryID == 0 {
+		return "", nil, errors.New("job_category_id wajib diisi")
+	}
+
+	tariff, err := s.Repo.GetActiveTariff(ctx, req.JobCategoryID, req.JobSubCategoryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, errors.New("tarif layanan belum tersedia")
+		}
+		return "", nil, err
+	}
+
+	q := &models.CustomerQuote{
+		CustomerID:    customerID,
+		JobCategoryID: req.JobCategoryID,
+		JobTariffID:   tariff.ID,
+		Price:         tariff.Price,
+		PlatformFee:   s.quoteAmountParam(ctx, "PLATFORM_FEE", req.JobCategoryID),
+		THRBonus:      s.quoteAmountParam(ctx, "THR_BONUS", req.JobCategoryID),
+	}
+	if req.JobSubCategoryID != 0 {
+		sub := req.JobSubCategoryID
+		q.JobSubCategoryID = &sub
+	}
+
+	// voucher hanya memotong harga layanan
+	if req.VoucherID != 0 || req.VoucherCode != "" {
+		v, discount, err := s.Repo.CheckVoucherEligibility(ctx, voucher.EligibilityInput{
+			VoucherID:        req.VoucherID,
+			Code:             req.VoucherCode,
+			UserID:           customerID,
+			JobCategoryID:    req.JobCategoryID,
+			JobSubCategoryID: req.JobSubCategoryID,
+			Amount:           q.Price,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		vid := int64(v.ID)
+		q.VoucherID = &vid
+		q.VoucherValue = discount
+	}
+
+	q.Total = q.Price + q.PlatformFee + q.THRBonus - q.VoucherValue
+
+	id, err := newQuoteID()
+	if err != nil {
+		return "", nil, err
+	}
+	q.ID = id
+	q.CreatedAt = time.Now()
+	q.ExpiresAt = q.CreatedAt.Add(time.Duration(s.intParam(ctx, "QUOTE_TTL_SECONDS", 300)) * time.Second)
+
+	token, err := signQuote(q)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := s.Repo.CreateQuote(ctx, q); err != nil {
+		return "", nil, err
+	}
+
+	return token, q, nil
+}
+
+// resolveQuote verifikasi tanda tangan + masa berlaku quote_id lalu ambil
+// quote milik customer yang belum dipakai
+func (s *Service) resolveQuote(ctx context.Context, customerID int64, token string) (*models.CustomerQuote, error) {
+	id, err := verifyQuoteToken(token, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.GetQuote(ctx, id, customerID)
+}
+
+// quoteAmountParam nominal rupiah dari global parameter (bisa per kategori)
+func (s *Service) quoteAmountParam(ctx context.Context, code string, jobCategoryID int64) float64 {
+	val := s.categoryParameter(ctx, code, jobCategoryID)
+	if val == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return f
+}
+
+// FORMAT quote_id: <id>.<expires_unix>.<signature>
+// signature = HMAC-SHA256(id|customer_id|expires_unix); rincian harga
+// tetap dibaca dari customer_quotes, bukan dari token
+
+func quoteSecret() ([]byte, error) {
+	secret := os.Getenv("QUOTE_SIGNING_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		return nil, errors.New("QUOTE_SIGNING_SECRET is not set")
+	}
+	return []byte(secret), nil
+}
+
+func quoteSignature(secret []byte, id string, customerID, expiresAt int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s|%d|%d", id, customerID, expiresAt)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signQuote(q *models.CustomerQuote) (string, error) {
+	secret, err := quoteSecret()
+	if err != nil {
+		return "", err
+	}
+
+	exp := q.ExpiresAt.Unix()
+	return fmt.Sprintf("%s.%d.%s", q.ID, exp, quoteSignature(secret, q.ID, q.CustomerID, exp)), nil
+}
+
+// verifyQuoteToken cek tanda tangan dan masa berlaku, return id quote
+func verifyQuoteToken(token string, customerID int64) (string, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return "", ErrQuoteInvalid
+	}
+
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrQuoteInvalid
+	}
+
+	secret, err := quoteSecret()
+	if err != nil {
+		return "", err
+	}
+
+	expected := quoteSignature(secret, parts[0], customerID, exp)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return "", ErrQuoteInvalid
+	}
+
+	if time.Now().Unix() >= exp {
+		return "", ErrQuoteInvalid
+	}
+
+	return parts[0], nil
+}
+
+func newQuoteID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "q_" + hex.EncodeToString(b), nil
+}
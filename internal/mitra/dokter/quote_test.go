@@ -0,0 +1,144 @@
+package dokter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"teka-api/internal/models"
+)
+
+func TestVerifyQuoteToken(t *testing.T) {
+	t.Setenv("QUOTE_SIGNING_SECRET", "test-secret")
+
+	const customerID int64 = 42
+
+	sign := func(t *testing.T, id string, customerID int64, expiresAt time.Time) string {
+		t.Helper()
+		token, err := signQuote(&models.CustomerQuote{ID: id, CustomerID: customerID, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatalf("signQuote: %v", err)
+		}
+		return token
+	}
+
+	valid := sign(t, "q_valid", customerID, time.Now().Add(5*time.Minute))
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name       string
+		token      string
+		customerID int64
+		wantID     string
+		wantErr    error
+	}{
+		{
+			name:       "valid",
+			token:      valid,
+			customerID: customerID,
+			wantID:     "q_valid",
+		},
+		{
+			name:       "valid with surrounding spaces",
+			token:      "  " + valid + " ",
+			customerID: customerID,
+			wantID:     "q_valid",
+		},
+		{
+			name:       "expired",
+			token:      sign(t, "q_expired", customerID, time.Now().Add(-time.Second)),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "wrong user",
+			token:      valid,
+			customerID: customerID + 1,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "tampered id",
+			token:      fmt.Sprintf("q_other.%s.%s", parts[1], parts[2]),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "tampered expiry",
+			token:      fmt.Sprintf("%s.%d.%s", parts[0], time.Now().Add(time.Hour).Unix(), parts[2]),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "tampered signature",
+			token:      fmt.Sprintf("%s.%s.%s", parts[0], parts[1], strings.Repeat("A", len(parts[2]))),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "signed with another secret",
+			token:      fmt.Sprintf("%s.%s.%s", parts[0], parts[1], quoteSignature([]byte("other-secret"), parts[0], customerID, mustParseInt(t, parts[1]))),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "non numeric expiry",
+			token:      fmt.Sprintf("%s.soon.%s", parts[0], parts[2]),
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "missing part",
+			token:      parts[0] + "." + parts[1],
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+		{
+			name:       "empty",
+			token:      "",
+			customerID: customerID,
+			wantErr:    ErrQuoteInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := verifyQuoteToken(tt.token, tt.customerID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if id != tt.wantID {
+				t.Fatalf("id = %q, want %q", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestVerifyQuoteTokenWithoutSecret(t *testing.T) {
+	t.Setenv("QUOTE_SIGNING_SECRET", "")
+	t.Setenv("JWT_SECRET", "")
+
+	if _, err := signQuote(&models.CustomerQuote{ID: "q_x", ExpiresAt: time.Now().Add(time.Minute)}); err == nil {
+		t.Fatal("signQuote without secret: want error")
+	}
+	if _, err := verifyQuoteToken("q_x.9999999999.sig", 1); err == nil || errors.Is(err, ErrQuoteInvalid) {
+		t.Fatalf("verifyQuoteToken without secret: err = %v, want config error", err)
+	}
+}
+
+func mustParseInt(t *testing.T, s string) int64 {
+	t.Helper()
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return n
+}
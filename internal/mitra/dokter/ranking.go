@@ -0,0 +1,49 @@
+package dokter
+
+import (
+	"context"
+	"strconv"
+)
+
+// RankingWeights bobot skor kandidat mitra (global_parameter RANK_WEIGHT_*)
+type RankingWeights struct {
+	Distance     float64
+	Rating       float64
+	Reviews      float64
+	Acceptance   float64
+	Cancellation float64
+}
+
+// default dipakai jika parameter belum diisi / tidak valid
+var defaultRankingWeights = RankingWeights{
+	Distance:     0.40,
+	Rating:       0.25,
+	Reviews:      0.10,
+	Acceptance:   0.15,
+	Cancellation: 0.10,
+}
+
+// GetRankingWeights ambil bobot ranking dari global parameter
+func (r *Repository) GetRankingWeights(ctx context.Context) RankingWeights {
+	w := defaultRankingWeights
+
+	params := map[string]*float64{
+		"RANK_WEIGHT_DISTANCE":     &w.Distance,
+		"RANK_WEIGHT_RATING":       &w.Rating,
+		"RANK_WEIGHT_REVIEWS":      &w.Reviews,
+		"RANK_WEIGHT_ACCEPTANCE":   &w.Acceptance,
+		"RANK_WEIGHT_CANCELLATION": &w.Cancellation,
+	}
+
+	for code, dst := range params {
+		val, err := r.GetGlobalParameter(ctx, code)
+		if err != nil || val == "" {
+			continue
+		}
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+			*dst = f
+		}
+	}
+
+	return w
+}
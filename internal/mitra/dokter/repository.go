@@ -7,8 +7,13 @@ import (
 	"log"
 	"strconv"
 	"teka-api/internal/models"
+	"teka-api/internal/referral"
+	"teka-api/internal/voucher"
+	"teka-api/pkg/database"
+	"teka-api/pkg/helper"
 	"time"
 
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/gorm"
 )
 
@@ -99,87 +104,242 @@ func (r *Repository) CreateUserRole(userID, roleID int, active bool) error {
 // END INSERT USER ROLES
 
 // START INSERT DOKUMEN
-func (r *Repository) CreateDocument(userID int, docType, url string) error {
-	query := `
-        INSERT INTO mitra_documents (user_id, doc_type, file_url, created_at, updated_at)
-        VALUES (?,?,?,NOW(),NOW());
-    `
-	return r.DB.Exec(query, userID, docType, url).Error
+// LicenseInfo nomor & masa berlaku dokumen lisensi (STR / SIP)
+type LicenseInfo struct {
+	Number    string
+	ExpiresAt *time.Time
+}
+
+// CreateDocument simpan dokumen baru (PENDING review). Dokumen aktif
+// sebelumnya dengan doc_type sama yang belum disetujui ditandai superseded;
+// dokumen APPROVED tetap berlaku sampai penggantinya disetujui (perpanjangan
+// lisensi tidak memutus dispatch).
+func (r *Repository) CreateDocument(userID int, docType string, file helper.StoredFile, license LicenseInfo) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return createDocumentTx(tx, userID, docType, file, license)
+	})
+}
+
+func createDocumentTx(tx *gorm.DB, userID int, docType string, file helper.StoredFile, license LicenseInfo) error {
+	if err := tx.Exec(`
+		UPDATE mitra_documents
+		SET superseded_at = NOW(), updated_at = NOW()
+		WHERE user_id = ? AND doc_type = ? AND superseded_at IS NULL
+		  AND status <> 'APPROVED'
+	`, userID, docType).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		INSERT INTO mitra_documents (
+			user_id, doc_type, file_url, status,
+			license_number, expires_at,
+			content_type, file_size, width, height, sha256, thumb_key,
+			created_at, updated_at
+		)
+		VALUES (
+			?, ?, ?, 'PENDING',
+			NULLIF(?, ''), ?,
+			NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''),
+			NOW(), NOW()
+		)
+	`,
+		userID, docType, file.Key,
+		license.Number, license.ExpiresAt,
+		file.ContentType, file.Size, file.Width, file.Height, file.SHA256, file.ThumbKey,
+	).Error
 }
 
 // END INSERT DOKUMEN
 
+// GetLocationStaleSeconds batas umur heartbeat lokasi mitra (default 300 detik)
+func (r *Repository) GetLocationStaleSeconds(ctx context.Context) int {
+	val, err := r.GetGlobalParameter(ctx, "LOCATION_STALE_SECONDS")
+	if err != nil || val == "" {
+		return 300
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 300
+	}
+	return n
+}
+
+// UpdateMitraLocation simpan heartbeat lokasi mitra yang sedang online
+func (r *Repository) UpdateMitraLocation(ctx context.Context, mitraID int64, lat, lng float64) error {
+	res := r.DB.WithContext(ctx).Exec(`
+		UPDATE mitra_details
+		SET current_latitude = ?,
+		    current_longitude = ?,
+		    current_location = point(?, ?),
+		    location_updated_at = NOW()
+		WHERE user_id = ?
+		  AND availability_status_id = 2
+	`, lat, lng, lng, lat, mitraID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("mitra sedang offline")
+	}
+	return nil
+}
+
 // START CARI DOKTER DALAM RADIUS
+// Kandidat diurutkan berdasarkan skor gabungan (bobot dari RANK_WEIGHT_*):
+//   - jarak          : 1 - distance/max_radius
+//   - rating         : rata-rata rating (bayesian, prior 4.0 dari 3 review) / 5
+//   - jumlah review  : ln(1+n) / ln(101), maksimal 1
+//   - acceptance rate: offer diterima / offer yang direspon (default 0.8 jika belum ada)
+//   - pembatalan     : order cancelled 30 hari terakhir (maks 5) → pengurang skor
+//
+// Posisi mitra diambil dari heartbeat lokasi terakhir; mitra yang heartbeat-nya
+// lebih tua dari LOCATION_STALE_SECONDS tidak ikut dicari.
+//
+// radius <= 0 → pakai MAX_RADIUS. excludeRequestID != 0 → skip mitra yang
+// sudah pernah ditawari request tersebut (dipakai saat perluasan radius).
 func (r *Repository) SearchDoctors(
 	ctx context.Context,
 	jobCategoryID int64,
 	jobSubCategoryID int64,
 	lat, lng float64,
+	radius float64,
+	excludeRequestID int64,
 ) ([]models.DoctorSearchResult, error) {
 
 	var doctors []models.DoctorSearchResult
 
-	maxRadius, err := r.GetMaxRadius(ctx)
-	if err != nil {
-		maxRadius = 10
+	if radius <= 0 {
+		maxRadius, err := r.GetMaxRadius(ctx)
+		if err != nil {
+			maxRadius = 10
+		}
+		radius = maxRadius
 	}
 
+	w := r.GetRankingWeights(ctx)
+
+	minLat, minLng, maxLat, maxLng := boundingBox(lat, lng, radius)
+
+	// 1. prefilter bounding box pakai GiST index mitra_details.current_location
+	// 2. jarak pasti (haversine) + filter availability hanya untuk hasil prefilter
+	// 3. statistik rating / offer / pembatalan per kandidat (LATERAL, pakai index mitra_id)
 	query := `
-	SELECT *
-FROM (
+	WITH candidates AS (
+		SELECT *
+		FROM (
+			SELECT
+				u.id AS mitra_id,
+				u.nama AS nama,
+				md.current_latitude  AS latitude,
+				md.current_longitude AS longitude,
+				` + haversineSQL("md.current_latitude", "md.current_longitude") + ` AS distance_km
+			FROM mitra_details md
+			JOIN users u ON u.id = md.user_id
+			JOIN user_roles ur ON ur.user_id = u.id
+			WHERE md.current_location <@ box(point(@min_lng, @min_lat), point(@max_lng, @max_lat))
+			  AND md.job_category_id = @cat
+			  AND (@sub = 0 OR md.job_sub_category_id = @sub)
+			  AND md.availability_status_id = 2
+			  AND md.verification_status = 'VERIFIED'
+			  AND ` + withinWorkingHoursSQL + `
+			  AND md.location_updated_at >= NOW() - (@stale * INTERVAL '1 second')
+			  AND ur.role_id = 2
+			  AND ur.active = true
+
+			  AND NOT EXISTS (
+				  SELECT 1
+				  FROM request_mitra_offers rmo
+				  WHERE rmo.mitra_id = u.id
+				    AND rmo.status_id = 1
+			  )
+
+			  AND NOT EXISTS (
+				  SELECT 1
+				  FROM service_orders so
+				  WHERE so.mitra_id = u.id
+				    AND so.status_id IN (1,2,3)
+			  )
+
+			  AND NOT EXISTS (
+				  SELECT 1
+				  FROM request_mitra_offers prev
+				  WHERE prev.request_id = @exclude
+				    AND prev.mitra_id = u.id
+			  )
+		) t
+		WHERE t.distance_km <= @radius
+	),
+	stats AS (
+		SELECT
+			c.*,
+			COALESCE(rt.avg_rating, 0)   AS avg_rating,
+			COALESCE(rt.total_review, 0) AS total_review,
+			COALESCE(oh.accepted::float / NULLIF(oh.responded, 0), 0.8) AS acceptance_rate,
+			COALESCE(cx.cancelled, 0)    AS recent_cancellations
+		FROM candidates c
+		LEFT JOIN LATERAL (
+			SELECT AVG(rating)::float AS avg_rating, COUNT(*) AS total_review
+			FROM mitra_ratings
+			WHERE mitra_id = c.mitra_id
+		) rt ON true
+		LEFT JOIN LATERAL (
+			SELECT
+				COUNT(*) FILTER (WHERE status_id = 2)         AS accepted,
+				COUNT(*) FILTER (WHERE status_id IN (2,3,4))  AS responded
+			FROM request_mitra_offers
+			WHERE mitra_id = c.mitra_id
+		) oh ON true
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS cancelled
+			FROM service_orders
+			WHERE mitra_id = c.mitra_id
+			  AND status_id = 5
+			  AND cancelled_by = 'MITRA' -- pembatalan oleh customer tidak menurunkan ranking
+			  AND updated_at >= NOW() - INTERVAL '30 days'
+		) cx ON true
+	)
 	SELECT
-		u.id AS mitra_id,
-		u.nama AS nama,
-		md.latitude,
-		md.longitude,
-		0 AS avg_rating,
-		0 AS total_review,
+		mitra_id,
+		nama,
+		latitude,
+		longitude,
+		avg_rating,
+		total_review,
+		distance_km,
+		acceptance_rate,
+		recent_cancellations,
 		(
-			6371 * acos(
-				cos(radians(?)) *
-				cos(radians(md.latitude)) *
-				cos(radians(md.longitude) - radians(?)) +
-				sin(radians(?)) *
-				sin(radians(md.latitude))
-			)
-		) AS distance_km
-	FROM mitra_details md
-	JOIN users u ON u.id = md.user_id
-	JOIN user_roles ur ON ur.user_id = u.id
-	WHERE md.job_category_id = ?
-	  AND (? = 0 OR md.job_sub_category_id = ?)
-	  AND md.availability_status_id = 2
-	  AND ur.role_id = 2
-	  AND ur.active = true
-
-	  AND NOT EXISTS (
-		  SELECT 1
-		  FROM request_mitra_offers rmo
-		  WHERE rmo.mitra_id = u.id
-		    AND rmo.status_id = 1
-	  )
-
-	  AND NOT EXISTS (
-		  SELECT 1
-		  FROM service_orders so
-		  WHERE so.mitra_id = u.id
-		    AND so.status_id IN (1,2,3)
-	  )
-) t
-WHERE t.distance_km <= ?
-ORDER BY t.distance_km ASC
-
-`
+			@w_dist * GREATEST(0, 1 - distance_km / NULLIF(@radius, 0)) +
+			@w_rating * ((avg_rating * total_review + 4.0 * 3) / (total_review + 3) / 5) +
+			@w_reviews * LEAST(1, ln(1 + total_review) / ln(101)) +
+			@w_accept * acceptance_rate -
+			@w_cancel * (LEAST(recent_cancellations, 5) / 5.0)
+		) AS score
+	FROM stats
+	ORDER BY score DESC, distance_km ASC
+	`
 
-	err = r.DB.WithContext(ctx).
-		Raw(
-			query,
-			lat, lng, lat,
-			jobCategoryID,
-			jobSubCategoryID,
-			jobSubCategoryID,
-			maxRadius,
-		).
+	err := r.DB.WithContext(ctx).
+		Raw(query, map[string]interface{}{
+			"lat":       lat,
+			"lng":       lng,
+			"cat":       jobCategoryID,
+			"sub":       jobSubCategoryID,
+			"radius":    radius,
+			"exclude":   excludeRequestID,
+			"min_lat":   minLat,
+			"min_lng":   minLng,
+			"max_lat":   maxLat,
+			"max_lng":   maxLng,
+			"stale":     r.GetLocationStaleSeconds(ctx),
+			"w_dist":    w.Distance,
+			"w_rating":  w.Rating,
+			"w_reviews": w.Reviews,
+			"w_accept":  w.Acceptance,
+			"w_cancel":  w.Cancellation,
+		}).
 		Scan(&doctors).Error
 
 	if err != nil {
@@ -192,40 +352,103 @@ ORDER BY t.distance_km ASC
 // END CARI DOKTER DALAM RADIUS
 
 // START CUSTOMER REQUEST
+// CreateCustomerRequest pakai (claim) quote customer sekali dalam transaksi
+// yang sama; kategori & seluruh komponen harga diambil dari quote, bukan
+// dari client.
 func (r *Repository) CreateCustomerRequest(
 	ctx context.Context,
 	req models.CustomerRequest,
 ) (int64, error) {
 
+	if req.QuoteID == nil || *req.QuoteID == "" {
+		return 0, errors.New("quote_id wajib diisi")
+	}
+
 	var id int64
 
+	statusID := req.StatusID
+	if statusID == 0 {
+		statusID = 1 // open
+	}
+
+	tx := r.DB.WithContext(ctx).Begin()
+
+	// 1. claim quote: milik customer, belum dipakai, belum kedaluwarsa
+	var quote models.CustomerQuote
+	claim := tx.Raw(`
+		UPDATE customer_quotes
+		SET used_at = NOW()
+		WHERE id = ?
+		  AND customer_id = ?
+		  AND used_at IS NULL
+		  AND expires_at > NOW()
+		RETURNING *
+	`, *req.QuoteID, req.CustomerID).Scan(&quote)
+	if claim.Error != nil {
+		tx.Rollback()
+		return 0, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		tx.Rollback()
+		return 0, ErrQuoteInvalid
+	}
+
 	query := `
 		INSERT INTO customer_requests (
 			customer_id, job_category_id, job_sub_category_id, 
 			keluhan, latitude, longitude, radius, price, 
 			voucher_id, voucher_value, platform_fee, thr_bonus,
-			status_id, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 1, NOW(), NOW())
+			search_radius, scheduled_at, status_id, quote_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW(), NOW())
 		RETURNING id
 	`
 
-	err := r.DB.WithContext(ctx).Raw(
+	// 2. request dengan harga dari quote
+	if err := tx.Raw(
 		query,
 		req.CustomerID,
-		req.JobCategoryID,
-		req.JobSubCategoryID,
+		quote.JobCategoryID,
+		quote.JobSubCategoryID,
 		req.Keluhan,
 		req.Latitude,
 		req.Longitude,
 		req.Radius,
-		req.Price,
-		req.VoucherID,
-		req.VoucherValue,
-		req.PlatformFee,
-		req.THRBonus,
-	).Scan(&id).Error
+		quote.Price,
+		quote.VoucherID,
+		quote.VoucherValue,
+		quote.PlatformFee,
+		quote.THRBonus,
+		req.SearchRadius,
+		req.ScheduledAt,
+		statusID,
+		quote.ID,
+	).Scan(&id).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	return id, err
+	// 3. reservasi kuota voucher; dilepas lagi saat request batal / expired
+	if quote.VoucherID != nil {
+		in := voucher.EligibilityInput{
+			VoucherID:     *quote.VoucherID,
+			UserID:        req.CustomerID,
+			JobCategoryID: quote.JobCategoryID,
+			Amount:        quote.Price,
+		}
+		if quote.JobSubCategoryID != nil {
+			in.JobSubCategoryID = *quote.JobSubCategoryID
+		}
+		if err := voucher.Reserve(tx, in, id, quote.VoucherValue); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 // Ambil customer request
@@ -240,7 +463,8 @@ func (r *Repository) GetCustomerRequest(ctx context.Context, requestID int64) (m
 // -------------------------------
 // CANCEL ORDERAN
 // -------------------------------
-func (r *Repository) CancelCustomerRequest(ctx context.Context, requestID int64) error {
+// CancelCustomerRequest return offer waiting yang ikut dibatalkan (untuk notif mitra)
+func (r *Repository) CancelCustomerRequest(ctx context.Context, requestID int64) ([]OfferTarget, error) {
 	tx := r.DB.WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -248,27 +472,189 @@ func (r *Repository) CancelCustomerRequest(ctx context.Context, requestID int64)
 		}
 	}()
 
-	// 1. Update customer request → hanya yang belum diterima mitra (status_id != 3 / accepted)
-	if err := tx.Exec(`
+	// 1. Update customer request → hanya yang belum diterima mitra
+	//    (1 = open, 6 = booking terjadwal). Matched / expired tidak boleh lewat
+	//    jalur ini supaya reservasi voucher tidak dilepas saat order berjalan.
+	res := tx.Exec(`
         UPDATE customer_requests
         SET status_id = ?, updated_at = NOW()
-        WHERE id = ? AND status_id NOT IN (3)  -- 3 = accepted/matched
-    `, 4, requestID).Error; err != nil { // 4 = cancelled
+        WHERE id = ? AND status_id IN (1, 6)
+    `, 4, requestID) // 4 = cancelled
+	if res.Error != nil {
 		tx.Rollback()
-		return err
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, errors.New("request sudah tidak bisa dibatalkan")
 	}
 
 	// 2. Update semua offer yang masih waiting (status_id = 1) menjadi cancelled (status_id = 5)
-	if err := tx.Exec(`
+	var withdrawn []OfferTarget
+	if err := tx.Raw(`
         UPDATE request_mitra_offers
         SET status_id = ?, responded_at = NOW()
         WHERE request_id = ? AND status_id = 1
-    `, 5, requestID).Error; err != nil { // 5 = cancelled sistem
+        RETURNING id AS offer_id, mitra_id, sequence
+    `, 5, requestID).Scan(&withdrawn).Error; err != nil { // 5 = cancelled sistem
 		tx.Rollback()
-		return err
+		return nil, err
 	}
 
-	return tx.Commit().Error
+	// 3. Kembalikan kuota voucher yang dipegang request
+	if err := voucher.Release(tx, requestID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return withdrawn, nil
+}
+
+// AppendOffers tambah offer hasil perluasan radius ke antrian request yang masih open.
+// Gelombang pertama langsung aktif. Return kosong jika request sudah tidak open
+// atau antrian ternyata sudah terisi (proses lain lebih dulu).
+func (r *Repository) AppendOffers(
+	ctx context.Context,
+	requestID int64,
+	radius float64,
+	doctors []models.DoctorSearchResult,
+	batchSize int,
+) ([]OfferTarget, error) {
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	tx := r.DB.WithContext(ctx).Begin()
+
+	var statusID int16
+	if err := tx.Raw(`
+		SELECT status_id FROM customer_requests WHERE id = ? FOR UPDATE
+	`, requestID).Scan(&statusID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var open int64
+	if err := tx.Raw(`
+		SELECT COUNT(*) FROM request_mitra_offers
+		WHERE request_id = ? AND status_id IN (1,6)
+	`, requestID).Scan(&open).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if statusID != 1 || open > 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	var lastSeq int
+	if err := tx.Raw(`
+		SELECT COALESCE(MAX(sequence), 0) FROM request_mitra_offers WHERE request_id = ?
+	`, requestID).Scan(&lastSeq).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	expiry, err := offerExpiryTx(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var sent []OfferTarget
+	for i, d := range doctors {
+		statusID := int16(6) // pending
+		var sentAt interface{}
+		var expiredAt *time.Time
+		if i < batchSize {
+			statusID = 1 // waiting
+			sentAt = gorm.Expr("NOW()")
+			expiredAt = &expiry
+		}
+
+		var offerID int64
+		if err := tx.Raw(`
+			INSERT INTO request_mitra_offers (request_id, mitra_id, sequence, status_id, sent_at, expired_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, requestID, d.MitraID, lastSeq+i+1, statusID, sentAt, expiredAt).Scan(&offerID).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if statusID == 1 {
+			sent = append(sent, OfferTarget{
+				OfferID:   offerID,
+				MitraID:   d.MitraID,
+				Nama:      d.Nama,
+				Sequence:  lastSeq + i + 1,
+				ExpiredAt: expiredAt,
+			})
+		}
+	}
+
+	if len(sent) > 0 {
+		if err := notifyOfferExpiryTx(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Exec(`
+		UPDATE customer_requests SET search_radius = ?, updated_at = NOW() WHERE id = ?
+	`, radius, requestID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return sent, nil
+}
+
+// ExpireCustomerRequest request tidak mendapat dokter → status 5 (expired).
+// Hanya jika masih open dan tidak ada offer yang sedang berjalan.
+func (r *Repository) ExpireCustomerRequest(ctx context.Context, requestID int64) (bool, error) {
+	tx := r.DB.WithContext(ctx).Begin()
+
+	res := tx.Exec(`
+		UPDATE customer_requests
+		SET status_id = 5, updated_at = NOW()
+		WHERE id = ?
+		  AND status_id = 1
+		  AND NOT EXISTS (
+			  SELECT 1 FROM request_mitra_offers
+			  WHERE request_id = ? AND status_id IN (1,6)
+		  )
+	`, requestID, requestID)
+	if res.Error != nil {
+		tx.Rollback()
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// kuota voucher dikembalikan bersama expire request
+	if err := voucher.Release(tx, requestID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 // START DETAIL CUSTOMER DI DOKTER
@@ -289,6 +675,8 @@ func (r *Repository) GetCurrentOfferForMitra(
 			r.longitude,
 			r.price,
 			r.created_at,
+			r.scheduled_at,
+			o.expired_at,
 			u.nama         AS customer_name,
 			u.phone        AS customer_phone
 		FROM request_mitra_offers o
@@ -311,14 +699,63 @@ func (r *Repository) GetCurrentOfferForMitra(
 	return &offer, nil
 }
 
+// GetOfferDetailForMitra detail offer waiting milik mitra (payload websocket offer)
+func (r *Repository) GetOfferDetailForMitra(
+	ctx context.Context,
+	offerID int64,
+	mitraID int64,
+) (*models.CurrentOffer, error) {
+
+	var offer models.CurrentOffer
+
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT
+			o.id           AS offer_id,
+			o.request_id,
+			r.customer_id,
+			r.keluhan,
+			r.latitude,
+			r.longitude,
+			r.price,
+			r.created_at,
+			r.scheduled_at,
+			o.expired_at,
+			u.nama         AS customer_name,
+			u.phone        AS customer_phone
+		FROM request_mitra_offers o
+		JOIN customer_requests r ON r.id = o.request_id
+		JOIN users u ON u.id = r.customer_id
+		WHERE o.id = ?
+		  AND o.mitra_id = ?
+		  AND o.status_id = 1
+	`, offerID, mitraID).Scan(&offer).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	if offer.OfferID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &offer, nil
+}
+
 // END DETAIL CUSTOMER DI DOKTER
 
 // START CREATE OFFER
+// batchSize = jumlah offer yang langsung dikirim (status 1), sisanya antri (status 6).
+// Return offer yang langsung dikirim.
 func (r *Repository) CreateOffers(
 	ctx context.Context,
 	requestID int64,
 	doctors []models.DoctorSearchResult,
-) error {
+	batchSize int,
+) ([]OfferTarget, error) {
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
 
 	tx := r.DB.WithContext(ctx).Begin()
 	defer func() {
@@ -327,42 +764,77 @@ func (r *Repository) CreateOffers(
 		}
 	}()
 
+	expiry, err := offerExpiryTx(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var sent []OfferTarget
 	for i, d := range doctors {
 		var statusID int16
 		var sentAt interface{}
+		var expiredAt *time.Time
 
-		if i == 0 {
-			// offer pertama langsung dikirim
+		if i < batchSize {
+			// gelombang pertama langsung dikirim
 			statusID = 1 // waiting
 			sentAt = gorm.Expr("NOW()")
+			expiredAt = &expiry
 		} else {
 			// sisanya ANTRI
 			statusID = 6 // pending
 			sentAt = nil
+			expiredAt = nil
 		}
 
-		if err := tx.Exec(`
+		var offerID int64
+		if err := tx.Raw(`
 			INSERT INTO request_mitra_offers (
 				request_id,
 				mitra_id,
 				sequence,
 				status_id,
-				sent_at
+				sent_at,
+				expired_at
 			)
-			VALUES (?, ?, ?, ?, ?)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id
 		`,
 			requestID,
 			d.MitraID,
 			i+1,
 			statusID,
 			sentAt,
-		).Error; err != nil {
+			expiredAt,
+		).Scan(&offerID).Error; err != nil {
 			tx.Rollback()
-			return err
+			return nil, err
+		}
+
+		if statusID == 1 {
+			sent = append(sent, OfferTarget{
+				OfferID:   offerID,
+				MitraID:   d.MitraID,
+				Nama:      d.Nama,
+				Sequence:  i + 1,
+				ExpiredAt: expiredAt,
+			})
 		}
 	}
 
-	return tx.Commit().Error
+	if len(sent) > 0 {
+		if err := notifyOfferExpiryTx(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return sent, nil
 }
 
 // END CREATE OFFER
@@ -396,8 +868,8 @@ func (r *Repository) GetOfferForAccept(
 		r.latitude  AS customer_latitude,
 		r.longitude AS customer_longitude,
 
-		md.latitude AS mitra_latitude,
-		md.longitude AS mitra_longitude,
+		COALESCE(md.current_latitude, md.latitude)   AS mitra_latitude,
+		COALESCE(md.current_longitude, md.longitude) AS mitra_longitude,
 
 		r.price,
 		r.voucher_id,
@@ -426,45 +898,68 @@ func (r *Repository) GetOfferForAccept(
 }
 
 // ACCEPT OFFER
+// Mengembalikan id service order baru + daftar mitra yang offer-nya ikut dibatalkan
 func (r *Repository) AcceptOfferAndCreateOrder(
 	ctx context.Context,
 	o *models.OfferAcceptData,
-) error {
+) (int64, []int64, error) {
 
 	tx := r.DB.WithContext(ctx).Begin()
 
-	// 1️⃣ Accept offer
+	// 1️⃣ Kunci customer request → dokter pertama yang accept menang
 	res := tx.Exec(`
+		UPDATE customer_requests
+		SET status_id = 2,
+		    matched_mitra_id = ?,
+		    matched_at = NOW()
+		WHERE id = ? AND status_id = 1
+	`, o.MitraID, o.RequestID)
+	if res.Error != nil {
+		tx.Rollback()
+		return 0, nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return 0, nil, errors.New("Maaf, order sudah diambil mitra lain")
+	}
+
+	// 2️⃣ Accept offer
+	res = tx.Exec(`
 		UPDATE request_mitra_offers
 		SET status_id = 2,
 		    responded_at = NOW()
 		WHERE id = ? AND status_id = 1
 	`, o.OfferID)
+	if res.Error != nil {
+		tx.Rollback()
+		return 0, nil, res.Error
+	}
 	if res.RowsAffected == 0 {
 		tx.Rollback()
-		return errors.New("Maaf, waktu penawaran sudah habis")
+		return 0, nil, errors.New("Maaf, waktu penawaran sudah habis")
+	}
+
+	// 3️⃣ Ambil mitra lain yang offer-nya sedang tampil → perlu dinotif offer ditarik
+	var notifyMitraIDs []int64
+	if err := tx.Raw(`
+		SELECT mitra_id
+		FROM request_mitra_offers
+		WHERE request_id = ? AND id != ? AND status_id = 1
+		FOR UPDATE
+	`, o.RequestID, o.OfferID).Scan(&notifyMitraIDs).Error; err != nil {
+		tx.Rollback()
+		return 0, nil, err
 	}
 
-	// 2️⃣ Cancel other offers
+	// Cancel other offers (waiting & antri)
 	if err := tx.Exec(`
 		UPDATE request_mitra_offers
-		SET status_id = 5
+		SET status_id = 5,
+		    responded_at = NOW()
 		WHERE request_id = ? AND id != ? AND status_id IN (1,6)
 	`, o.RequestID, o.OfferID).Error; err != nil {
 		tx.Rollback()
-		return err
-	}
-
-	// 3️⃣ Update customer request
-	if err := tx.Exec(`
-		UPDATE customer_requests
-		SET status_id = 2,
-		    matched_mitra_id = ?,
-		    matched_at = NOW()
-		WHERE id = ? AND status_id = 1
-	`, o.MitraID, o.RequestID).Error; err != nil {
-		tx.Rollback()
-		return err
+		return 0, nil, err
 	}
 
 	// 4️⃣ INSERT service_order + ambil id & created_at
@@ -539,23 +1034,32 @@ func (r *Repository) AcceptOfferAndCreateOrder(
 		o.VoucherValue,
 	).Row().Scan(&orderID, &createdAt); err != nil {
 		tx.Rollback()
-		return err
+		return 0, nil, err
 	}
 
+	// nomor order: <prefix vertical>-<waktu>-<seq>, mis. DOK-... / NRS-...
 	if err := tx.Exec(`
-	UPDATE service_orders
+	UPDATE service_orders so
 SET order_number = format(
-	'DOK-%s-%s',
-	to_char(created_at, 'YYYYMMDDHH24MISSMS'),
+	'%s-%s-%s',
+	COALESCE(
+		(SELECT NULLIF(UPPER(TRIM(jc.order_prefix)), '') FROM job_categories jc WHERE jc.id = so.job_category_id),
+		'ORD'
+	),
+	to_char(so.created_at, 'YYYYMMDDHH24MISSMS'),
 	to_char(nextval('myschema.service_order_seq'), 'FM000000')
 )
-WHERE id = ?;
+WHERE so.id = ?;
 `, orderID).Error; err != nil {
 		tx.Rollback()
-		return err
+		return 0, nil, err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return 0, nil, err
+	}
+
+	return orderID, notifyMitraIDs, nil
 }
 
 // AKTIF ORDER FOR CUSTOMER
@@ -740,12 +1244,9 @@ func (r *Repository) CompleteOrder(
 		return err
 	}
 
-	// 4️⃣ foto bukti
-	for _, url := range data.Attachments {
-		if err := tx.Exec(`
-			INSERT INTO order_attachments (order_id, type, url)
-			VALUES (?, 'photo', ?)
-		`, orderID, url).Error; err != nil {
+	// 4️⃣ lampiran bukti layanan
+	for _, att := range data.Attachments {
+		if _, err := InsertOrderAttachmentTx(tx, orderID, att.Type, att.File, mitraID); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -785,126 +1286,317 @@ func (r *Repository) CompleteOrderCustomer(
 	return nil
 }
 
-func (r *Repository) RejectOffer(
+// GetOfferRequestInfo ambil request + kategori dari offer milik mitra
+func (r *Repository) GetOfferRequestInfo(
 	ctx context.Context,
 	offerID int64,
-) error {
+	mitraID int64,
+) (requestID int64, jobCategoryID int64, err error) {
+
+	var row struct {
+		RequestID     int64
+		JobCategoryID int64
+	}
+
+	err = r.DB.WithContext(ctx).Raw(`
+		SELECT o.request_id, r.job_category_id
+		FROM request_mitra_offers o
+		JOIN customer_requests r ON r.id = o.request_id
+		WHERE o.id = ?
+		  AND o.mitra_id = ?
+		  AND o.status_id = 1
+	`, offerID, mitraID).Scan(&row).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if row.RequestID == 0 {
+		return 0, 0, errors.New("Maaf, waktu penawaran sudah habis")
+	}
+
+	return row.RequestID, row.JobCategoryID, nil
+}
+
+// RejectOfferAndMoveNext tolak offer (status 3) + simpan alasan,
+// lalu langsung aktifkan antrian berikutnya seperti timeout worker.
+func (r *Repository) RejectOfferAndMoveNext(
+	ctx context.Context,
+	offerID int64,
+	mitraID int64,
+	reasonCode string,
+	reasonNote string,
+	batchSize int,
+) (next []OfferTarget, exhausted bool, err error) {
 
 	tx := r.DB.WithContext(ctx).Begin()
 
+	var offer struct {
+		RequestID     int64
+		RequestStatus int16
+	}
+	if err := tx.Raw(`
+		SELECT o.request_id, r.status_id AS request_status
+		FROM request_mitra_offers o
+		JOIN customer_requests r ON r.id = o.request_id
+		WHERE o.id = ? AND o.mitra_id = ?
+		FOR UPDATE OF r
+	`, offerID, mitraID).Scan(&offer).Error; err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+
 	// 1. reject offer sekarang
-	if err := tx.Exec(`
+	res := tx.Exec(`
 		UPDATE request_mitra_offers
-		SET status = 'rejected',
-		    status_id = 3,
-		    responded_at = now()
+		SET status_id = 3,
+		    reject_reason_code = NULLIF(?, ''),
+		    reject_reason_note = NULLIF(?, ''),
+		    responded_at = NOW()
 		WHERE id = ?
-		  AND status = 'waiting'
-	`, offerID).Error; err != nil {
+		  AND mitra_id = ?
+		  AND status_id = 1
+	`, reasonCode, reasonNote, offerID, mitraID)
+	if res.Error != nil {
 		tx.Rollback()
-		return err
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, false, errors.New("Maaf, waktu penawaran sudah habis")
 	}
 
 	// 2. aktifkan offer berikutnya
-	if err := tx.Exec(`
-		UPDATE request_mitra_offers
-		SET status = 'waiting',
-		    status_id = 1,
-		    sent_at = now()
-		WHERE id = (
-			SELECT id FROM request_mitra_offers
-			WHERE request_id = (
-				SELECT request_id FROM request_mitra_offers WHERE id = ?
-			)
-			AND status = 'cancelled'
-			ORDER BY sequence
-			LIMIT 1
-		)
-	`, offerID).Error; err != nil {
+	next, exhausted, err = r.moveNextTx(tx, offer.RequestID, offer.RequestStatus, batchSize)
+	if err != nil {
 		tx.Rollback()
-		return err
+		return nil, false, err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, false, err
+	}
+
+	return next, exhausted, nil
 }
 
-func (r *Repository) GetExpiredOffers(
-	ctx context.Context,
-	timeoutSeconds int,
-) ([]models.ExpiredOffer, error) {
+// GetExpiredOffers offer waiting yang expired_at-nya sudah lewat
+func (r *Repository) GetExpiredOffers(ctx context.Context) ([]models.ExpiredOffer, error) {
 
 	var offers []models.ExpiredOffer
 
 	err := r.DB.WithContext(ctx).Raw(`
-		SELECT o.id, o.request_id, o.sequence, u.nama as mitra_name
+		SELECT o.id, o.request_id, o.sequence, o.mitra_id, u.nama as mitra_name, r.job_category_id
 		FROM request_mitra_offers o
 		JOIN users u ON u.id = o.mitra_id
+		JOIN customer_requests r ON r.id = o.request_id
 		WHERE o.status_id = 1
-		  AND o.sent_at IS NOT NULL
-		  AND o.sent_at <= clock_timestamp() - (? * INTERVAL '1 second')
-	`, timeoutSeconds).Scan(&offers).Error
+		  AND o.expired_at <= clock_timestamp()
+		ORDER BY o.expired_at
+	`).Scan(&offers).Error
 
 	return offers, err
 }
 
+// NextOfferExpiryIn sisa waktu sampai offer waiting berikutnya expired,
+// dihitung di DB supaya tidak terpengaruh selisih jam server. nil jika
+// tidak ada offer waiting.
+func (r *Repository) NextOfferExpiryIn(ctx context.Context) (*time.Duration, error) {
+	var seconds *float64
+
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT EXTRACT(EPOCH FROM MIN(expired_at) - clock_timestamp())::float8
+		FROM request_mitra_offers
+		WHERE status_id = 1
+		  AND expired_at IS NOT NULL
+	`).Scan(&seconds).Error
+	if err != nil || seconds == nil {
+		return nil, err
+	}
+
+	d := time.Duration(*seconds * float64(time.Second))
+	return &d, nil
+}
+
+// ListenOfferExpiry LISTEN channel offer expiry di koneksi khusus, kirim
+// sinyal ke wake setiap ada offer baru dijadwalkan (dari replica mana pun).
+// Blocking sampai ctx selesai / koneksi error.
+func (r *Repository) ListenOfferExpiry(ctx context.Context, wake chan<- struct{}) error {
+	sqlDB, err := r.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("LISTEN not supported by driver %T", driverConn)
+		}
+
+		if _, err := pc.Conn().Exec(ctx, "LISTEN "+offerExpiryChannel); err != nil {
+			return err
+		}
+
+		for {
+			if _, err := pc.Conn().WaitForNotification(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// TimeoutAndMoveNext tandai offer timeout, lalu jika tidak ada lagi offer
+// yang sedang waiting untuk request ini aktifkan batchSize offer antrian berikutnya.
+// exhausted = true jika request masih open tapi antrian sudah habis.
+// timedOut = false jika tidak ada yang diubah: request sedang dikunci proses
+// lain, atau offer sudah diterima / ditolak lebih dulu.
 func (r *Repository) TimeoutAndMoveNext(
 	ctx context.Context,
 	requestID int64,
 	sequence int,
-) (int64, string, error) {
+	batchSize int,
+) (next []OfferTarget, exhausted bool, timedOut bool, err error) {
 
 	tx := r.DB.WithContext(ctx).Begin()
 
+	// 0. kunci customer request supaya tidak balapan dengan accept / timeout lain.
+	// SKIP LOCKED: jika sedang diproses transaksi lain, lewati dan coba di tick berikutnya
+	var requestStatus int16
+	lock := tx.Raw(`
+		SELECT status_id FROM customer_requests WHERE id = ? FOR UPDATE SKIP LOCKED
+	`, requestID).Scan(&requestStatus)
+	if lock.Error != nil {
+		tx.Rollback()
+		return nil, false, false, lock.Error
+	}
+	if lock.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, false, false, nil
+	}
+
 	// 1. timeout current offer
-	if err := tx.Exec(`
+	res := tx.Exec(`
 		UPDATE request_mitra_offers
 		SET status_id = 4,
 		    responded_at = NOW()
 		WHERE request_id = ?
 		  AND sequence = ?
 		  AND status_id = 1
-	`, requestID, sequence).Error; err != nil {
+	`, requestID, sequence)
+	if res.Error != nil {
 		tx.Rollback()
-		return 0, "", err
+		return nil, false, false, res.Error
 	}
 
-	// 2. Cari next mitra_id & name
-	var next struct {
-		MitraID int64
-		Nama    string `gorm:"column:nama"`
+	// sudah diproses (accept / reject / worker lain) → jangan kirim offer lagi
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, false, false, nil
 	}
-	err := tx.Raw(`
-		SELECT o.mitra_id, u.nama
+
+	// 2. aktifkan antrian berikutnya
+	next, exhausted, err = r.moveNextTx(tx, requestID, requestStatus, batchSize)
+	if err != nil {
+		tx.Rollback()
+		return nil, false, false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, false, false, err
+	}
+
+	return next, exhausted, true, nil
+}
+
+// moveNextTx aktifkan gelombang offer berikutnya di dalam transaksi yang
+// sudah mengunci customer_requests. Tidak commit / rollback.
+func (r *Repository) moveNextTx(
+	tx *gorm.DB,
+	requestID int64,
+	requestStatus int16,
+	batchSize int,
+) ([]OfferTarget, bool, error) {
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	// request sudah matched / cancelled → jangan kirim offer baru
+	if requestStatus != 1 {
+		return nil, false, nil
+	}
+
+	// masih ada offer gelombang ini yang waiting → tunggu
+	var waiting int64
+	if err := tx.Raw(`
+		SELECT COUNT(*) FROM request_mitra_offers
+		WHERE request_id = ? AND status_id = 1
+	`, requestID).Scan(&waiting).Error; err != nil {
+		return nil, false, err
+	}
+	if waiting > 0 {
+		return nil, false, nil
+	}
+
+	// Cari gelombang berikutnya
+	var next []OfferTarget
+	if err := tx.Raw(`
+		SELECT o.id AS offer_id, o.mitra_id, u.nama, o.sequence
 		FROM request_mitra_offers o
 		JOIN users u ON u.id = o.mitra_id
-		WHERE o.request_id = ? AND o.sequence = ? AND o.status_id = 6
-		LIMIT 1
-	`, requestID, sequence+1).Scan(&next).Error
+		WHERE o.request_id = ? AND o.status_id = 6
+		ORDER BY o.sequence
+		LIMIT ?
+	`, requestID, batchSize).Scan(&next).Error; err != nil {
+		return nil, false, err
+	}
 
-	if err != nil || next.MitraID == 0 {
+	if len(next) == 0 {
 		// No more offers in queue
-		tx.Commit()
-		return 0, "", nil
+		return nil, true, nil
+	}
+
+	expiry, err := offerExpiryTx(tx)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// 3. activate next offer (ONLY ONE)
+	offerIDs := make([]int64, 0, len(next))
+	for i := range next {
+		offerIDs = append(offerIDs, next[i].OfferID)
+		next[i].ExpiredAt = &expiry
+	}
+
+	// activate next offers
 	if err := tx.Exec(`
 		UPDATE request_mitra_offers
 		SET status_id = 1,
-		    sent_at = NOW()
-		WHERE request_id = ?
-		  AND sequence = ?
+		    sent_at = NOW(),
+		    expired_at = ?
+		WHERE id IN ?
 		  AND status_id = 6
-	`, requestID, sequence+1).Error; err != nil {
-		tx.Rollback()
-		return 0, "", err
+	`, expiry, offerIDs).Error; err != nil {
+		return nil, false, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return 0, "", err
+	if err := notifyOfferExpiryTx(tx); err != nil {
+		return nil, false, err
 	}
 
-	return next.MitraID, next.Nama, nil
+	return next, false, nil
 }
 
 func (r *Repository) GetServiceForRating(
@@ -1148,6 +1840,29 @@ func (r *Repository) DeductCustomerBalance(
 		}
 	}()
 
+	// 0. Klaim order: kunci baris service_orders, lewati jika sedang diproses
+	// transaksi lain (worker auto-complete / konfirmasi customer bersamaan)
+	var orderStatus int16
+	claim := tx.Raw(`
+		SELECT status_id
+		FROM myschema.service_orders
+		WHERE id = ? AND customer_id = ?
+		FOR UPDATE SKIP LOCKED
+	`, orderID, customerID).Scan(&orderStatus)
+	if claim.Error != nil {
+		tx.Rollback()
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("order sedang diproses, silakan coba lagi")
+	}
+	if orderStatus != 4 {
+		log.Printf("[DeductCustomerBalance] Order %d already processed (status %d)", orderID, orderStatus)
+		tx.Rollback()
+		return errors.New("order tidak ditemukan atau status tidak valid untuk diselesaikan")
+	}
+
 	// 1. Get order info and amount
 	var orderNo string
 	var amount float64
@@ -1164,6 +1879,8 @@ func (r *Repository) DeductCustomerBalance(
 	}
 	log.Printf("[DeductCustomerBalance] Order number: %s, Amount: %.2f", orderNo, amount)
 
+	mitraLabel := orderMitraLabelTx(tx, orderID)
+
 	// 2. Get latest balance from saldo_role_transactions (Role ID 1 = Customer)
 	var latestSaldo int64
 
@@ -1203,7 +1920,7 @@ func (r *Repository) DeductCustomerBalance(
 		CategoryID:    2, // Order Payment
 		Amount:        intAmount,
 		SaldoSetelah:  newSaldo,
-		Description:   "Pembayaran order Dokter",
+		Description:   "Pembayaran order " + mitraLabel,
 		CreatedAt:     time.Now(),
 	}
 
@@ -1251,6 +1968,32 @@ func (r *Repository) DeductCustomerBalance(
 		return errors.New("order tidak ditemukan atau status tidak valid untuk diselesaikan")
 	}
 
+	// 5b. Catat pemakaian voucher (reserved → used_count + voucher_histories)
+	var requestID int64
+	if err := tx.Raw(`
+		SELECT request_id FROM myschema.service_orders WHERE id = ?
+	`, orderID).Scan(&requestID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := voucher.Redeem(tx, requestID, orderID); err != nil {
+		log.Printf("[DeductCustomerBalance] Error redeeming voucher: %v", err)
+		tx.Rollback()
+		return err
+	}
+
+	// 5c. Reward referral jika ini order pertama customer yang selesai
+	reward, err := referral.RewardFirstOrder(tx, customerID, orderID, orderNo)
+	if err != nil {
+		log.Printf("[DeductCustomerBalance] Error rewarding referral: %v", err)
+		tx.Rollback()
+		return err
+	}
+	if reward != nil {
+		log.Printf("[DeductCustomerBalance] Referral rewarded: referrer %d +%d, customer %d +%d",
+			reward.ReferrerID, reward.ReferrerReward, customerID, reward.RefereeReward)
+	}
+
 	// 6. Record Mitra Income (Simplified)
 	var trans struct {
 		MitraID     int64
@@ -1299,7 +2042,7 @@ func (r *Repository) DeductCustomerBalance(
 		CategoryID:    3, // Pendapatan
 		Amount:        mitraIncomeInt,
 		SaldoSetelah:  mitraNewSaldo,
-		Description:   "Pendapatan order Dokter",
+		Description:   "Pendapatan order " + mitraLabel,
 		CreatedAt:     time.Now(),
 	}).Error; err != nil {
 		log.Printf("[DeductCustomerBalance] Error inserting mitra income: %v", err)
@@ -1646,3 +2389,1276 @@ func (r *Repository) GetExpiredOrdersToComplete(ctx context.Context, timeoutMinu
 	err := r.DB.WithContext(ctx).Raw(query, timeoutMinutes).Scan(&results).Error
 	return results, err
 }
+
+// haversineSQL ekspresi jarak (km) dari titik @lat/@lng ke kolom lat/lng
+func haversineSQL(latCol, lngCol string) string {
+	return fmt.Sprintf(`(
+		6371 * acos(LEAST(1,
+			cos(radians(@lat)) *
+			cos(radians(%[1]s)) *
+			cos(radians(%[2]s) - radians(@lng)) +
+			sin(radians(@lat)) *
+			sin(radians(%[1]s))
+		))
+	)`, latCol, lngCol)
+}
+
+// offerExpiryChannel channel LISTEN/NOTIFY scheduler expiry offer
+const offerExpiryChannel = "offer_expiry"
+
+// offerExpirySQL expired_at untuk offer yang baru dikirim
+// (NOW() + OFFER_TIMEOUT_SECONDS, default 60 detik)
+const offerExpirySQL = `NOW() + (
+	COALESCE((
+		SELECT NULLIF(parameter_value, '')::int
+		FROM global_parameter
+		WHERE parameter_code = 'OFFER_TIMEOUT_SECONDS' AND is_active = true
+		LIMIT 1
+	), 60) * INTERVAL '1 second'
+)`
+
+// offerExpiryTx expired_at untuk offer yang dikirim di transaksi ini
+func offerExpiryTx(tx *gorm.DB) (time.Time, error) {
+	var expiry time.Time
+	err := tx.Raw(`SELECT ` + offerExpirySQL).Scan(&expiry).Error
+	return expiry, err
+}
+
+// notifyOfferExpiryTx bangunkan scheduler expiry (terkirim saat commit)
+func notifyOfferExpiryTx(tx *gorm.DB) error {
+	return tx.Exec(`SELECT pg_notify(?, '')`, offerExpiryChannel).Error
+}
+
+// BOOKING TERJADWAL
+
+// CountBookingSlot hitung kapasitas mitra di area vs booking yang sudah ada
+// dalam rentang slot (scheduledAt ± slotMinutes).
+func (r *Repository) CountBookingSlot(
+	ctx context.Context,
+	jobCategoryID int64,
+	jobSubCategoryID int64,
+	lat, lng float64,
+	radius float64,
+	scheduledAt time.Time,
+	slotMinutes int,
+) (capacity int64, booked int64, err error) {
+
+	params := map[string]interface{}{
+		"lat":    lat,
+		"lng":    lng,
+		"cat":    jobCategoryID,
+		"sub":    jobSubCategoryID,
+		"radius": radius,
+		"at":     scheduledAt,
+		"slot":   slotMinutes,
+	}
+
+	err = r.DB.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM mitra_details md
+		JOIN user_roles ur ON ur.user_id = md.user_id
+		WHERE md.job_category_id = @cat
+		  AND (@sub = 0 OR md.job_sub_category_id = @sub)
+		  AND ur.role_id = 2
+		  AND ur.active = true
+		  AND `+haversineSQL("md.latitude", "md.longitude")+` <= @radius
+	`, params).Scan(&capacity).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = r.DB.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM customer_requests cr
+		WHERE cr.job_category_id = @cat
+		  AND cr.status_id IN (1, 2, 6)
+		  AND cr.scheduled_at IS NOT NULL
+		  AND cr.scheduled_at > @at - (@slot * INTERVAL '1 minute')
+		  AND cr.scheduled_at < @at + (@slot * INTERVAL '1 minute')
+		  AND `+haversineSQL("cr.latitude", "cr.longitude")+` <= @radius
+	`, params).Scan(&booked).Error
+
+	return capacity, booked, err
+}
+
+// HasOverlappingBooking cek customer sudah punya booking di slot yang sama
+func (r *Repository) HasOverlappingBooking(
+	ctx context.Context,
+	customerID int64,
+	scheduledAt time.Time,
+	slotMinutes int,
+) (bool, error) {
+
+	var exists bool
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM customer_requests
+			WHERE customer_id = ?
+			  AND status_id IN (1, 2, 6)
+			  AND scheduled_at IS NOT NULL
+			  AND scheduled_at > ?::timestamptz - (? * INTERVAL '1 minute')
+			  AND scheduled_at < ?::timestamptz + (? * INTERVAL '1 minute')
+		)
+	`, customerID, scheduledAt, slotMinutes, scheduledAt, slotMinutes).Scan(&exists).Error
+
+	return exists, err
+}
+
+// ClaimDueBookings ubah booking (status 6) yang sudah masuk waktu dispatch
+// menjadi open (status 1) dan kembalikan datanya.
+func (r *Repository) ClaimDueBookings(ctx context.Context, leadMinutes int) ([]models.CustomerRequest, error) {
+	var reqs []models.CustomerRequest
+
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE customer_requests
+		SET status_id = 1, updated_at = NOW()
+		WHERE status_id = 6
+		  AND scheduled_at <= NOW() + (? * INTERVAL '1 minute')
+		RETURNING *
+	`, leadMinutes).Scan(&reqs).Error
+
+	return reqs, err
+}
+
+// RequeueBooking kembalikan booking yang gagal di-dispatch ke status
+// terjadwal, hanya jika masih open dan belum ada offer sama sekali
+func (r *Repository) RequeueBooking(ctx context.Context, requestID int64) (bool, error) {
+	res := r.DB.WithContext(ctx).Exec(`
+		UPDATE customer_requests
+		SET status_id = 6, updated_at = NOW()
+		WHERE id = ?
+		  AND status_id = 1
+		  AND scheduled_at IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM request_mitra_offers WHERE request_id = ?
+		  )
+	`, requestID, requestID)
+	return res.RowsAffected > 0, res.Error
+}
+
+// RequeueStaleBookings booking yang sudah diklaim (status 1) tapi tidak
+// punya offer sama sekali setelah staleMinutes: proses mati / leader
+// berganti di antara klaim dan dispatch. Dikembalikan ke status 6 supaya
+// diklaim ulang oleh ClaimDueBookings.
+func (r *Repository) RequeueStaleBookings(ctx context.Context, staleMinutes int) ([]int64, error) {
+	var ids []int64
+
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE customer_requests cr
+		SET status_id = 6, updated_at = NOW()
+		WHERE cr.status_id = 1
+		  AND cr.scheduled_at IS NOT NULL
+		  AND cr.updated_at < NOW() - (? * INTERVAL '1 minute')
+		  AND NOT EXISTS (
+			SELECT 1 FROM request_mitra_offers WHERE request_id = cr.id
+		  )
+		RETURNING cr.id
+	`, staleMinutes).Scan(&ids).Error
+
+	return ids, err
+}
+
+// BookingReminder data booking yang perlu diingatkan
+type BookingReminder struct {
+	ID             int64
+	CustomerID     int64
+	JobCategoryID  int64
+	MatchedMitraID *int64
+	ScheduledAt    time.Time
+}
+
+// ClaimBookingReminders tandai reminder terkirim untuk booking yang
+// scheduled_at-nya tinggal reminderMinutes lagi.
+func (r *Repository) ClaimBookingReminders(ctx context.Context, reminderMinutes int) ([]BookingReminder, error) {
+	var rows []BookingReminder
+
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE customer_requests
+		SET reminder_sent_at = NOW()
+		WHERE scheduled_at IS NOT NULL
+		  AND reminder_sent_at IS NULL
+		  AND status_id IN (1, 2, 6)
+		  AND scheduled_at > NOW()
+		  AND scheduled_at <= NOW() + (? * INTERVAL '1 minute')
+		RETURNING id, customer_id, job_category_id, matched_mitra_id, scheduled_at
+	`, reminderMinutes).Scan(&rows).Error
+
+	return rows, err
+}
+
+// AVAILABILITY & JAM KERJA
+
+// withinWorkingHoursSQL mitra tanpa jadwal dianggap selalu tersedia,
+// mitra dengan jadwal hanya tersedia di dalam jam kerjanya (WIB)
+const withinWorkingHoursSQL = `(
+	NOT EXISTS (
+		SELECT 1 FROM mitra_working_hours wh WHERE wh.user_id = md.user_id
+	)
+	OR EXISTS (
+		SELECT 1 FROM mitra_working_hours wh
+		WHERE wh.user_id = md.user_id
+		  AND wh.day_of_week = EXTRACT(DOW FROM NOW() AT TIME ZONE 'Asia/Jakarta')
+		  AND (NOW() AT TIME ZONE 'Asia/Jakarta')::time >= wh.start_time
+		  AND (NOW() AT TIME ZONE 'Asia/Jakarta')::time < wh.end_time
+	)
+)`
+
+// SetAvailability ubah status online/offline mitra
+func (r *Repository) SetAvailability(ctx context.Context, mitraID int64, statusID int16) error {
+	res := r.DB.WithContext(ctx).Exec(`
+		UPDATE mitra_details
+		SET availability_status_id = ?, updated_at = NOW()
+		WHERE user_id = ?
+	`, statusID, mitraID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("mitra not found")
+	}
+	return nil
+}
+
+// GetWorkingHours jadwal mingguan mitra
+func (r *Repository) GetWorkingHours(ctx context.Context, mitraID int64) ([]models.MitraWorkingHour, error) {
+	var hours []models.MitraWorkingHour
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT
+			day_of_week,
+			to_char(start_time, 'HH24:MI') AS start_time,
+			to_char(end_time, 'HH24:MI')   AS end_time
+		FROM mitra_working_hours
+		WHERE user_id = ?
+		ORDER BY day_of_week, start_time
+	`, mitraID).Scan(&hours).Error
+	return hours, err
+}
+
+// ReplaceWorkingHours ganti seluruh jadwal mingguan mitra
+func (r *Repository) ReplaceWorkingHours(ctx context.Context, mitraID int64, hours []models.MitraWorkingHour) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM mitra_working_hours WHERE user_id = ?`, mitraID).Error; err != nil {
+			return err
+		}
+
+		for _, h := range hours {
+			if err := tx.Exec(`
+				INSERT INTO mitra_working_hours (user_id, day_of_week, start_time, end_time, created_at, updated_at)
+				VALUES (?, ?, ?::time, ?::time, NOW(), NOW())
+			`, mitraID, h.DayOfWeek, h.StartTime, h.EndTime).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetOfflineOutsideWorkingHours mitra online yang sudah di luar jam kerja
+// (dan tidak sedang melayani order) otomatis di-offline-kan
+func (r *Repository) SetOfflineOutsideWorkingHours(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE mitra_details md
+		SET availability_status_id = 1, updated_at = NOW()
+		WHERE md.availability_status_id = 2
+		  AND EXISTS (
+			  SELECT 1 FROM mitra_working_hours wh WHERE wh.user_id = md.user_id
+		  )
+		  AND NOT ` + withinWorkingHoursSQL + `
+		  AND NOT EXISTS (
+			  SELECT 1 FROM service_orders so
+			  WHERE so.mitra_id = md.user_id
+			    AND so.status_id IN (1,2,3)
+		  )
+		RETURNING md.user_id
+	`).Scan(&ids).Error
+	return ids, err
+}
+
+// PEMBATALAN SERVICE ORDER
+
+// CancelOrderInfo data service order untuk validasi pembatalan
+type CancelOrderInfo struct {
+	ID            int64
+	RequestID     int64
+	CustomerID    int64
+	MitraID       int64
+	StatusID      int16
+	OrderNumber   string
+	JobCategoryID int64
+}
+
+// GetOrderForCancel ambil service order yang akan dibatalkan
+func (r *Repository) GetOrderForCancel(ctx context.Context, orderID int64) (CancelOrderInfo, error) {
+	var o CancelOrderInfo
+
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT id, request_id, customer_id, mitra_id, status_id,
+		       COALESCE(order_number, '') AS order_number,
+		       job_category_id
+		FROM service_orders
+		WHERE id = ?
+	`, orderID).Scan(&o).Error
+	if err != nil {
+		return o, err
+	}
+	if o.ID == 0 {
+		return o, gorm.ErrRecordNotFound
+	}
+
+	return o, nil
+}
+
+// CancelServiceOrderInput data pembatalan yang sudah divalidasi service
+type CancelServiceOrderInput struct {
+	Order        CancelOrderInfo
+	CancelledBy  string // CUSTOMER / MITRA
+	ReasonCode   string
+	ReasonNote   string
+	Fee          int64 // dipotong dari saldo pihak yang membatalkan
+	SharePercent int64 // bagian biaya batal customer untuk mitra
+	Redispatch   bool  // buka lagi customer request untuk dokter lain
+}
+
+// CancelServiceOrderResult nominal yang benar-benar tercatat di saldo
+type CancelServiceOrderResult struct {
+	FeeCharged   int64 `json:"fee_charged"`
+	Compensation int64 `json:"compensation"`
+	Redispatched bool  `json:"redispatched"`
+}
+
+// CancelServiceOrder batalkan service order (status 5) + catat biaya batal
+// di saldo + tutup / buka lagi customer request dalam satu transaksi.
+// Gagal jika status order sudah berubah sejak divalidasi.
+func (r *Repository) CancelServiceOrder(
+	ctx context.Context,
+	in CancelServiceOrderInput,
+) (CancelServiceOrderResult, error) {
+
+	var result CancelServiceOrderResult
+
+	tx := r.DB.WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 1. status order → 5 (cancelled)
+	res := tx.Exec(`
+		UPDATE service_orders
+		SET status_id = 5,
+		    cancelled_by = ?,
+		    cancel_reason_code = ?,
+		    cancel_reason_note = NULLIF(?, ''),
+		    cancelled_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = ? AND status_id = ?
+	`, in.CancelledBy, in.ReasonCode, in.ReasonNote, in.Order.ID, in.Order.StatusID)
+	if res.Error != nil {
+		tx.Rollback()
+		return result, res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return result, errors.New("status order sudah berubah, silakan coba lagi")
+	}
+
+	// 2. biaya batal dari pihak yang membatalkan
+	payerID := in.Order.CustomerID
+	mitraLabel := orderMitraLabelTx(tx, in.Order.ID)
+	desc := "Biaya pembatalan order " + mitraLabel
+	if in.CancelledBy == CancelledByMitra {
+		payerID = in.Order.MitraID
+		desc = "Penalti pembatalan order " + mitraLabel
+	}
+
+	if in.Fee > 0 {
+		charged, err := bookSaldoTx(tx, payerID, in.Order.OrderNumber, "OUT", 5, in.Fee, desc)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		result.FeeCharged = charged
+
+		if err := tx.Exec(`
+			UPDATE service_orders SET cancellation_fee = ? WHERE id = ?
+		`, charged, in.Order.ID).Error; err != nil {
+			tx.Rollback()
+			return result, err
+		}
+	}
+
+	// 3. kompensasi mitra dari biaya batal customer
+	if in.CancelledBy == CancelledByCustomer && result.FeeCharged > 0 && in.SharePercent > 0 {
+		comp := result.FeeCharged * in.SharePercent / 100
+		if comp > 0 {
+			booked, err := bookSaldoTx(tx, in.Order.MitraID, in.Order.OrderNumber, "IN", 6, comp,
+				"Kompensasi pembatalan order "+mitraLabel)
+			if err != nil {
+				tx.Rollback()
+				return result, err
+			}
+			result.Compensation = booked
+		}
+	}
+
+	// 4. customer request: buka lagi untuk dokter lain atau tutup
+	if in.Redispatch {
+		res = tx.Exec(`
+			UPDATE customer_requests
+			SET status_id = 1,
+			    matched_mitra_id = NULL,
+			    matched_at = NULL,
+			    updated_at = NOW()
+			WHERE id = ? AND status_id = 2
+		`, in.Order.RequestID)
+		if res.Error != nil {
+			tx.Rollback()
+			return result, res.Error
+		}
+		result.Redispatched = res.RowsAffected > 0
+	} else {
+		if err := tx.Exec(`
+			UPDATE customer_requests
+			SET status_id = 4, updated_at = NOW()
+			WHERE id = ? AND status_id = 2
+		`, in.Order.RequestID).Error; err != nil {
+			tx.Rollback()
+			return result, err
+		}
+
+		// request ditutup → kuota voucher dikembalikan
+		if err := voucher.Release(tx, in.Order.RequestID); err != nil {
+			tx.Rollback()
+			return result, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return CancelServiceOrderResult{}, err
+	}
+
+	return result, nil
+}
+
+// orderMitraLabelTx sebutan mitra vertical order (Dokter, Perawat, ...)
+// untuk deskripsi mutasi saldo
+func orderMitraLabelTx(tx *gorm.DB, orderID int64) string {
+	var label string
+	if err := tx.Raw(`
+		SELECT COALESCE(NULLIF(TRIM(jc.mitra_label), ''), 'Mitra')
+		FROM service_orders so
+		JOIN job_categories jc ON jc.id = so.job_category_id
+		WHERE so.id = ?
+	`, orderID).Scan(&label).Error; err != nil || label == "" {
+		return "Mitra"
+	}
+	return label
+}
+
+// bookSaldoTx catat mutasi saldo user di dalam transaksi (kunci baris saldo
+// terakhir). Mutasi OUT dibatasi saldo yang ada supaya saldo tidak minus.
+// Return nominal yang tercatat.
+func bookSaldoTx(
+	tx *gorm.DB,
+	userID int64,
+	referenceID string,
+	mutationType string,
+	categoryID int,
+	amount int64,
+	description string,
+) (int64, error) {
+
+	var latestSaldo int64
+	err := tx.Raw(`
+		SELECT saldo_setelah
+		FROM myschema.saldo_role_transactions
+		WHERE user_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`, userID).Scan(&latestSaldo).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	newSaldo := latestSaldo + amount
+	if mutationType == "OUT" {
+		if amount > latestSaldo {
+			amount = latestSaldo
+		}
+		newSaldo = latestSaldo - amount
+	}
+
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	if err := tx.Create(&models.SaldoTransaction{
+		UserID:        uint(userID),
+		ReferenceID:   referenceID,
+		ReferenceType: "CANCELLATION",
+		MutationType:  mutationType,
+		CategoryID:    categoryID,
+		Amount:        amount,
+		SaldoSetelah:  newSaldo,
+		Description:   description,
+		CreatedAt:     time.Now(),
+	}).Error; err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+// QUOTATION HARGA
+
+// ErrQuoteInvalid quote tidak ditemukan / bukan milik customer / sudah dipakai / kedaluwarsa
+var ErrQuoteInvalid = errors.New("quote tidak valid atau sudah kedaluwarsa, silakan minta quote baru")
+
+// GetActiveTariff tarif aktif hari ini untuk kategori; tarif khusus sub
+// kategori didahulukan dari tarif umum kategori
+func (r *Repository) GetActiveTariff(ctx context.Context, jobCategoryID, jobSubCategoryID int64) (*models.JobTariff, error) {
+	var tariff models.JobTariff
+
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT *
+		FROM job_tariffs
+		WHERE job_category_id = ?
+		  AND (job_sub_category_id = ? OR job_sub_category_id IS NULL)
+		  AND is_active = true
+		  AND start_date <= NOW()
+		  AND (end_date IS NULL OR end_date >= NOW())
+		ORDER BY job_sub_category_id NULLS LAST, start_date DESC
+		LIMIT 1
+	`, jobCategoryID, jobSubCategoryID).Scan(&tariff).Error
+	if err != nil {
+		return nil, err
+	}
+	if tariff.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &tariff, nil
+}
+
+// CheckVoucherEligibility validasi voucher customer + hitung potongan
+func (r *Repository) CheckVoucherEligibility(ctx context.Context, in voucher.EligibilityInput) (*models.Voucher, float64, error) {
+	return voucher.CheckEligibility(r.DB.WithContext(ctx), in)
+}
+
+// CreateQuote simpan quote baru
+func (r *Repository) CreateQuote(ctx context.Context, q *models.CustomerQuote) error {
+	return r.DB.WithContext(ctx).Create(q).Error
+}
+
+// GetQuote quote customer yang belum dipakai dan belum kedaluwarsa
+func (r *Repository) GetQuote(ctx context.Context, quoteID string, customerID int64) (*models.CustomerQuote, error) {
+	var q models.CustomerQuote
+
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT *
+		FROM customer_quotes
+		WHERE id = ?
+		  AND customer_id = ?
+		  AND used_at IS NULL
+		  AND expires_at > NOW()
+	`, quoteID, customerID).Scan(&q).Error
+	if err != nil {
+		return nil, err
+	}
+	if q.ID == "" {
+		return nil, ErrQuoteInvalid
+	}
+
+	return &q, nil
+}
+
+// KYC DOKUMEN MITRA
+
+// GetDocumentReviewQueue dokumen aktif per status untuk review admin (terlama dulu)
+func (r *Repository) GetDocumentReviewQueue(ctx context.Context, status string, limit, offset int) ([]models.MitraDocumentReview, error) {
+	var rows []models.MitraDocumentReview
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT
+			d.id, d.user_id, d.doc_type, d.file_url, d.status,
+			d.license_number, d.expires_at,
+			d.content_type, d.file_size, d.width, d.height, d.sha256, d.thumb_key,
+			d.review_comment, d.reviewed_by, d.reviewed_at, d.created_at,
+			u.nama AS mitra_name,
+			u.phone AS mitra_phone,
+			md.job_category_id,
+			md.verification_status
+		FROM mitra_documents d
+		JOIN users u ON u.id = d.user_id
+		JOIN mitra_details md ON md.user_id = d.user_id
+		WHERE d.superseded_at IS NULL
+		  AND d.status = ?
+		ORDER BY d.created_at ASC
+		LIMIT ? OFFSET ?
+	`, status, limit, offset).Scan(&rows).Error
+	return rows, err
+}
+
+// GetCurrentDocuments dokumen aktif milik mitra
+func (r *Repository) GetCurrentDocuments(ctx context.Context, userID int64) ([]models.MitraDocument, error) {
+	var docs []models.MitraDocument
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT id, user_id, doc_type, file_url, status,
+		       license_number, expires_at,
+		       content_type, file_size, width, height, sha256, thumb_key,
+		       review_comment, reviewed_by, reviewed_at, created_at
+		FROM mitra_documents
+		WHERE user_id = ? AND superseded_at IS NULL
+		ORDER BY doc_type, created_at
+	`, userID).Scan(&docs).Error
+	return docs, err
+}
+
+// GetDocument ambil satu dokumen mitra
+func (r *Repository) GetDocument(ctx context.Context, docID int64) (models.MitraDocument, error) {
+	var doc models.MitraDocument
+	res := r.DB.WithContext(ctx).Raw(`
+		SELECT id, user_id, doc_type, file_url, status,
+		       license_number, expires_at,
+		       content_type, file_size, width, height, sha256, thumb_key,
+		       review_comment, reviewed_by, reviewed_at, created_at
+		FROM mitra_documents
+		WHERE id = ?
+	`, docID).Scan(&doc)
+	if res.Error != nil {
+		return doc, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return doc, gorm.ErrRecordNotFound
+	}
+	return doc, nil
+}
+
+// ReviewDocument simpan keputusan review dokumen yang masih PENDING.
+// license (opsional) mengoreksi nomor / masa berlaku lisensi. Dokumen yang
+// disetujui menggantikan dokumen lama dengan doc_type sama.
+// Return dokumen setelah direview.
+func (r *Repository) ReviewDocument(
+	ctx context.Context,
+	docID int64,
+	reviewerID int64,
+	status string,
+	comment string,
+	license LicenseInfo,
+) (models.MitraDocument, error) {
+	var doc models.MitraDocument
+
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Raw(`
+			UPDATE mitra_documents
+			SET status = ?,
+			    review_comment = NULLIF(?, ''),
+			    license_number = COALESCE(NULLIF(?, ''), license_number),
+			    expires_at = COALESCE(?, expires_at),
+			    reviewed_by = ?,
+			    reviewed_at = NOW(),
+			    updated_at = NOW()
+			WHERE id = ?
+			  AND superseded_at IS NULL
+			  AND status = 'PENDING'
+			RETURNING id, user_id, doc_type, file_url, status,
+			          license_number, expires_at,
+			          content_type, file_size, width, height, sha256, thumb_key,
+			          review_comment, reviewed_by, reviewed_at, created_at
+		`, status, comment, license.Number, license.ExpiresAt, reviewerID, docID).Scan(&doc)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("dokumen tidak ditemukan, sudah direview, atau sudah diganti")
+		}
+
+		if status != "APPROVED" {
+			return nil
+		}
+
+		return tx.Exec(`
+			UPDATE mitra_documents
+			SET superseded_at = NOW(), updated_at = NOW()
+			WHERE user_id = ? AND doc_type = ? AND id <> ? AND superseded_at IS NULL
+		`, doc.UserID, doc.DocType, doc.ID).Error
+	})
+
+	return doc, err
+}
+
+// MitraVerificationRow status verifikasi di mitra_details
+type MitraVerificationRow struct {
+	UserID             int64
+	JobCategoryID      int64
+	VerificationStatus string
+	VerifiedAt         *time.Time
+}
+
+// GetMitraVerification status verifikasi mitra
+func (r *Repository) GetMitraVerification(ctx context.Context, userID int64) (MitraVerificationRow, error) {
+	var row MitraVerificationRow
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT user_id, job_category_id, verification_status, verified_at
+		FROM mitra_details
+		WHERE user_id = ?
+	`, userID).Scan(&row).Error
+	if err != nil {
+		return row, err
+	}
+	if row.UserID == 0 {
+		return row, gorm.ErrRecordNotFound
+	}
+	return row, nil
+}
+
+// SetVerificationStatus ubah status verifikasi; mitra yang tidak lagi
+// VERIFIED otomatis offline. Return false jika status tidak berubah.
+func (r *Repository) SetVerificationStatus(ctx context.Context, userID int64, status string) (bool, error) {
+	res := r.DB.WithContext(ctx).Exec(`
+		UPDATE mitra_details
+		SET verification_status = @status,
+		    verified_at = CASE WHEN @status = 'VERIFIED' THEN NOW() ELSE NULL END,
+		    availability_status_id = CASE WHEN @status = 'VERIFIED' THEN availability_status_id ELSE @offline END,
+		    updated_at = NOW()
+		WHERE user_id = @user_id
+		  AND verification_status <> @status
+	`, map[string]interface{}{
+		"status":  status,
+		"offline": AvailabilityOffline,
+		"user_id": userID,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// LICENSE EXPIRY
+
+// LicenseReminder dokumen lisensi yang perlu diingatkan perpanjangannya
+type LicenseReminder struct {
+	DocID         int64
+	UserID        int64
+	DocType       string
+	LicenseNumber *string
+	ExpiresAt     time.Time
+	DaysLeft      int
+	Email         string
+	Nama          string
+}
+
+// ClaimLicenseReminders tandai & ambil lisensi aktif yang sisa masa
+// berlakunya <= days dan belum diingatkan pada ambang ini. Dipanggil dari
+// ambang terkecil supaya satu dokumen hanya dapat satu reminder per hari.
+func (r *Repository) ClaimLicenseReminders(ctx context.Context, days int) ([]LicenseReminder, error) {
+	var rows []LicenseReminder
+	err := r.DB.WithContext(ctx).Raw(`
+		WITH claimed AS (
+			UPDATE mitra_documents d
+			SET last_expiry_reminder_days = @days
+			WHERE d.superseded_at IS NULL
+			  AND d.status = 'APPROVED'
+			  AND d.expires_at IS NOT NULL
+			  AND d.expires_at >= CURRENT_DATE
+			  AND d.expires_at - CURRENT_DATE <= @days
+			  AND (d.last_expiry_reminder_days IS NULL OR d.last_expiry_reminder_days > @days)
+			  -- perpanjangan sudah diupload, tidak perlu diingatkan lagi
+			  AND NOT EXISTS (
+				  SELECT 1 FROM mitra_documents n
+				  WHERE n.user_id = d.user_id
+				    AND n.doc_type = d.doc_type
+				    AND n.id > d.id
+				    AND n.superseded_at IS NULL
+				    AND n.status = 'PENDING'
+			  )
+			RETURNING d.id, d.user_id, d.doc_type, d.license_number, d.expires_at
+		)
+		SELECT
+			c.id AS doc_id,
+			c.user_id,
+			c.doc_type,
+			c.license_number,
+			c.expires_at,
+			(c.expires_at - CURRENT_DATE) AS days_left,
+			u.email,
+			u.nama
+		FROM claimed c
+		JOIN users u ON u.id = c.user_id
+	`, map[string]interface{}{"days": days}).Scan(&rows).Error
+	return rows, err
+}
+
+// GetMitraWithExpiredLicenses mitra VERIFIED yang punya lisensi aktif
+// sudah kedaluwarsa
+func (r *Repository) GetMitraWithExpiredLicenses(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT DISTINCT d.user_id
+		FROM mitra_documents d
+		JOIN mitra_details md ON md.user_id = d.user_id
+		WHERE d.superseded_at IS NULL
+		  AND d.status = 'APPROVED'
+		  AND d.expires_at < CURRENT_DATE
+		  AND md.verification_status = 'VERIFIED'
+	`).Scan(&ids).Error
+	return ids, err
+}
+
+// LicenseMissingExpiry lisensi APPROVED aktif tanpa tanggal berlaku
+type LicenseMissingExpiry struct {
+	UserID  int64
+	DocType string
+}
+
+// GetMitraWithMissingLicenseExpiry mitra VERIFIED yang lisensinya (data
+// sebelum masa berlaku dicatat) belum punya expires_at
+func (r *Repository) GetMitraWithMissingLicenseExpiry(ctx context.Context, licenseTypes []string) ([]LicenseMissingExpiry, error) {
+	var list []LicenseMissingExpiry
+	if len(licenseTypes) == 0 {
+		return list, nil
+	}
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT DISTINCT ON (d.user_id) d.user_id, d.doc_type
+		FROM mitra_documents d
+		JOIN mitra_details md ON md.user_id = d.user_id
+		WHERE d.superseded_at IS NULL
+		  AND d.status = 'APPROVED'
+		  AND d.expires_at IS NULL
+		  AND d.doc_type IN ?
+		  AND md.verification_status = 'VERIFIED'
+		ORDER BY d.user_id, d.doc_type
+	`, licenseTypes).Scan(&list).Error
+	return list, err
+}
+
+// MITRA PROFILE
+
+// MitraProfileRow data profil mitra dari mitra_details + users
+type MitraProfileRow struct {
+	UserID               int64
+	Nama                 string
+	Email                string
+	Phone                string
+	JobCategoryID        int64
+	JobCategoryName      string
+	JobSubCategoryID     *int64
+	JobSubCategoryName   *string
+	Bio                  *string
+	PhotoKey             *string
+	PhotoThumbKey        *string
+	PracticeAddress      *string
+	Specialities         string // JSONB array
+	VerificationStatus   string
+	VerifiedAt           *time.Time
+	AvailabilityStatusID int16
+	CompletedOrders      int64
+}
+
+// GetMitraProfile profil mitra. Foto default ke dokumen "foto" yang disetujui
+// jika mitra belum pernah mengganti foto profil.
+func (r *Repository) GetMitraProfile(ctx context.Context, userID int64) (MitraProfileRow, error) {
+	var row MitraProfileRow
+	res := r.DB.WithContext(ctx).Raw(`
+		SELECT
+			md.user_id,
+			u.nama,
+			u.email,
+			u.phone,
+			md.job_category_id,
+			jc.name AS job_category_name,
+			md.job_sub_category_id,
+			jsc.sub_category_name AS job_sub_category_name,
+			md.bio,
+			COALESCE(md.photo_object_key, (
+				SELECT d.file_url
+				FROM mitra_documents d
+				WHERE d.user_id = md.user_id
+				  AND d.doc_type = 'foto'
+				  AND d.status = 'APPROVED'
+				  AND d.superseded_at IS NULL
+				ORDER BY d.id DESC
+				LIMIT 1
+			)) AS photo_key,
+			COALESCE(md.photo_thumb_key, (
+				SELECT d.thumb_key
+				FROM mitra_documents d
+				WHERE d.user_id = md.user_id
+				  AND d.doc_type = 'foto'
+				  AND d.status = 'APPROVED'
+				  AND d.superseded_at IS NULL
+				ORDER BY d.id DESC
+				LIMIT 1
+			)) AS photo_thumb_key,
+			md.practice_address,
+			COALESCE(md.specialities, '[]'::jsonb)::text AS specialities,
+			md.verification_status,
+			md.verified_at,
+			COALESCE(md.availability_status_id, 1) AS availability_status_id,
+			(
+				SELECT COUNT(*)
+				FROM service_orders so
+				WHERE so.mitra_id = md.user_id AND so.status_id = 6
+			) AS completed_orders
+		FROM mitra_details md
+		JOIN users u ON u.id = md.user_id
+		JOIN job_categories jc ON jc.id = md.job_category_id
+		LEFT JOIN job_sub_categories jsc ON jsc.id = md.job_sub_category_id
+		WHERE md.user_id = ?
+	`, userID).Scan(&row)
+	if res.Error != nil {
+		return row, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return row, gorm.ErrRecordNotFound
+	}
+	return row, nil
+}
+
+// UpdateMitraProfile ubah bio / alamat praktik / spesialisasi (nil = tidak diubah)
+func (r *Repository) UpdateMitraProfile(
+	ctx context.Context,
+	userID int64,
+	bio *string,
+	practiceAddress *string,
+	specialities *string,
+) error {
+	params := map[string]interface{}{
+		"set_bio":          bio != nil,
+		"bio":              "",
+		"set_address":      practiceAddress != nil,
+		"address":          "",
+		"set_specialities": specialities != nil,
+		"specialities":     "[]",
+		"user_id":          userID,
+	}
+	if bio != nil {
+		params["bio"] = *bio
+	}
+	if practiceAddress != nil {
+		params["address"] = *practiceAddress
+	}
+	if specialities != nil {
+		params["specialities"] = *specialities
+	}
+
+	res := r.DB.WithContext(ctx).Exec(`
+		UPDATE mitra_details
+		SET bio = CASE WHEN @set_bio THEN NULLIF(@bio, '') ELSE bio END,
+		    practice_address = CASE WHEN @set_address THEN NULLIF(@address, '') ELSE practice_address END,
+		    specialities = CASE WHEN @set_specialities THEN CAST(@specialities AS jsonb) ELSE specialities END,
+		    updated_at = NOW()
+		WHERE user_id = @user_id
+	`, params)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateMitraPhoto ganti foto profil, return object key foto & thumbnail lama
+func (r *Repository) UpdateMitraPhoto(ctx context.Context, userID int64, file helper.StoredFile) ([]string, error) {
+	var old struct {
+		PhotoObjectKey *string
+		PhotoThumbKey  *string
+	}
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Raw(`
+			SELECT photo_object_key, photo_thumb_key
+			FROM mitra_details
+			WHERE user_id = ?
+			FOR UPDATE
+		`, userID).Scan(&old)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Exec(`
+			UPDATE mitra_details
+			SET photo_object_key = ?, photo_thumb_key = NULLIF(?, ''), updated_at = NOW()
+			WHERE user_id = ?
+		`, file.Key, file.ThumbKey, userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, k := range []*string{old.PhotoObjectKey, old.PhotoThumbKey} {
+		if k != nil && *k != "" {
+			keys = append(keys, *k)
+		}
+	}
+	return keys, nil
+}
+
+// FILE ACCESS
+
+// IsAdmin user punya role admin aktif
+func (r *Repository) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	return database.IsAdmin(ctx, r.DB, userID)
+}
+
+// OrderAttachmentRow lampiran order + pihak yang terlibat di order
+type OrderAttachmentRow struct {
+	ID         int64
+	OrderID    int64
+	Type       string
+	URL        string // object key
+	CustomerID int64
+	MitraID    int64
+}
+
+// GetOrderAttachment ambil lampiran order beserta customer & mitra order
+func (r *Repository) GetOrderAttachment(ctx context.Context, attachmentID int64) (OrderAttachmentRow, error) {
+	var row OrderAttachmentRow
+	res := r.DB.WithContext(ctx).Raw(`
+		SELECT oa.id, oa.order_id, oa.type, oa.url, so.customer_id, so.mitra_id
+		FROM order_attachments oa
+		JOIN service_orders so ON so.id = oa.order_id
+		WHERE oa.id = ?
+	`, attachmentID).Scan(&row)
+	if res.Error != nil {
+		return row, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return row, gorm.ErrRecordNotFound
+	}
+	return row, nil
+}
+
+// HasOrderWithMitra customer pernah / sedang punya service order dengan mitra
+func (r *Repository) HasOrderWithMitra(ctx context.Context, customerID, mitraID int64) (bool, error) {
+	var ok bool
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM service_orders
+			WHERE customer_id = ? AND mitra_id = ?
+		)
+	`, customerID, mitraID).Scan(&ok).Error
+	return ok, err
+}
+
+// UPLOAD SESSION
+
+const uploadSessionColumns = `
+	id, user_id, purpose, doc_type, attachment_type, order_id, bucket, object_key,
+	content_type, declared_size, actual_size, status, fail_reason,
+	attachment_id, expires_at, finalized_at, created_at
+`
+
+// CreateUploadSession simpan sesi upload baru (PENDING)
+func (r *Repository) CreateUploadSession(ctx context.Context, sess models.UploadSession) (models.UploadSession, error) {
+	var out models.UploadSession
+	err := r.DB.WithContext(ctx).Raw(`
+		INSERT INTO upload_sessions (
+			user_id, purpose, doc_type, attachment_type, order_id, bucket, object_key,
+			content_type, declared_size, status, expires_at, created_at, updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'PENDING', ?, NOW(), NOW())
+		RETURNING `+uploadSessionColumns,
+		sess.UserID, sess.Purpose, sess.DocType, sess.AttachmentType, sess.OrderID, sess.Bucket, sess.ObjectKey,
+		sess.ContentType, sess.DeclaredSize, sess.ExpiresAt,
+	).Scan(&out).Error
+	return out, err
+}
+
+// GetUploadSession ambil sesi upload milik user
+func (r *Repository) GetUploadSession(ctx context.Context, userID, sessionID int64) (models.UploadSession, error) {
+	var sess models.UploadSession
+	res := r.DB.WithContext(ctx).Raw(`
+		SELECT `+uploadSessionColumns+`
+		FROM upload_sessions
+		WHERE id = ? AND user_id = ?
+	`, sessionID, userID).Scan(&sess)
+	if res.Error != nil {
+		return sess, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return sess, gorm.ErrRecordNotFound
+	}
+	return sess, nil
+}
+
+// ExpireUploadSessions tandai sesi PENDING yang sudah lewat expires_at +
+// grace sebagai FAILED, return sesi tersebut supaya object-nya dihapus.
+// Grace memberi waktu finalize yang sedang berjalan untuk selesai.
+func (r *Repository) ExpireUploadSessions(ctx context.Context, grace time.Duration, limit int) ([]models.UploadSession, error) {
+	var list []models.UploadSession
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE upload_sessions
+		SET status = 'FAILED', fail_reason = 'upload session kedaluwarsa', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM upload_sessions
+			WHERE status = 'PENDING'
+			  AND expires_at < NOW() - (? * INTERVAL '1 second')
+			ORDER BY expires_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+uploadSessionColumns,
+		int64(grace.Seconds()), limit,
+	).Scan(&list).Error
+	return list, err
+}
+
+// FailUploadSession tandai sesi PENDING gagal diverifikasi
+func (r *Repository) FailUploadSession(ctx context.Context, sessionID int64, actualSize *int64, reason string) error {
+	return r.DB.WithContext(ctx).Exec(`
+		UPDATE upload_sessions
+		SET status = 'FAILED', actual_size = ?, fail_reason = ?, updated_at = NOW()
+		WHERE id = ? AND status = 'PENDING'
+	`, actualSize, reason, sessionID).Error
+}
+
+// FinalizeUploadSession klaim sesi PENDING → FINALIZED lalu jalankan attach
+// di transaksi yang sama. Return false jika sesi sudah diproses request lain.
+func (r *Repository) FinalizeUploadSession(
+	ctx context.Context,
+	sessionID int64,
+	actualSize int64,
+	attach func(tx *gorm.DB) (*int64, error),
+) (bool, error) {
+	claimed := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(`
+			UPDATE upload_sessions
+			SET status = 'FINALIZED', actual_size = ?, finalized_at = NOW(), updated_at = NOW()
+			WHERE id = ? AND status = 'PENDING'
+		`, actualSize, sessionID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		claimed = true
+
+		attachmentID, err := attach(tx)
+		if err != nil {
+			return err
+		}
+		if attachmentID == nil {
+			return nil
+		}
+		return tx.Exec(`
+			UPDATE upload_sessions SET attachment_id = ? WHERE id = ?
+		`, *attachmentID, sessionID).Error
+	})
+	return claimed && err == nil, err
+}
+
+// InsertOrderAttachmentTx tambah lampiran order, return id
+func InsertOrderAttachmentTx(
+	tx *gorm.DB,
+	orderID int64,
+	attachmentType string,
+	file helper.StoredFile,
+	uploadedBy int64,
+) (int64, error) {
+	var id int64
+	err := tx.Raw(`
+		INSERT INTO order_attachments (
+			order_id, type, url,
+			content_type, file_size, width, height, sha256, thumb_key,
+			uploaded_by, created_at
+		)
+		VALUES (
+			?, ?, ?,
+			NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''),
+			?, NOW()
+		)
+		RETURNING id
+	`,
+		orderID, attachmentType, file.Key,
+		file.ContentType, file.Size, file.Width, file.Height, file.SHA256, file.ThumbKey,
+		uploadedBy,
+	).Scan(&id).Error
+	return id, err
+}
+
+const orderAttachmentColumns = `
+	id, order_id, type, url, thumb_key, content_type,
+	file_size, width, height, uploaded_by, created_at
+`
+
+// AddOrderAttachment tambah lampiran bukti layanan selama order ARRIVED
+// milik mitra. Cek status di query yang sama supaya tidak balapan dengan
+// complete / cancel.
+func (r *Repository) AddOrderAttachment(
+	ctx context.Context,
+	orderID, mitraID int64,
+	attachmentType string,
+	file helper.StoredFile,
+) (models.OrderAttachment, error) {
+	var att models.OrderAttachment
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked int64
+		res := tx.Raw(`
+			SELECT id FROM service_orders
+			WHERE id = ? AND mitra_id = ? AND status_id = 3 -- ARRIVED
+			FOR UPDATE
+		`, orderID, mitraID).Scan(&locked)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("lampiran hanya bisa ditambahkan saat mitra sudah tiba")
+		}
+
+		id, err := InsertOrderAttachmentTx(tx, orderID, attachmentType, file, mitraID)
+		if err != nil {
+			return err
+		}
+		return tx.Raw(`
+			SELECT `+orderAttachmentColumns+`
+			FROM order_attachments
+			WHERE id = ?
+		`, id).Scan(&att).Error
+	})
+	return att, err
+}
+
+// GetOrderAttachments lampiran bukti layanan order (tanpa lampiran chat)
+func (r *Repository) GetOrderAttachments(ctx context.Context, orderID int64) ([]models.OrderAttachment, error) {
+	var list []models.OrderAttachment
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT `+orderAttachmentColumns+`
+		FROM order_attachments
+		WHERE order_id = ?
+		  AND type <> 'chat'
+		ORDER BY created_at, id
+	`, orderID).Scan(&list).Error
+	return list, err
+}
+
+// OrderParticipants customer, mitra & status service order
+type OrderParticipants struct {
+	CustomerID int64
+	MitraID    int64
+	StatusID   int16
+}
+
+// GetOrderParticipants pihak yang terlibat di service order
+func (r *Repository) GetOrderParticipants(ctx context.Context, orderID int64) (OrderParticipants, error) {
+	var p OrderParticipants
+	res := r.DB.WithContext(ctx).Raw(`
+		SELECT customer_id, mitra_id, status_id
+		FROM service_orders
+		WHERE id = ?
+	`, orderID).Scan(&p)
+	if res.Error != nil {
+		return p, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return p, gorm.ErrRecordNotFound
+	}
+	return p, nil
+}
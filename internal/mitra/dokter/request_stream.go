@@ -0,0 +1,43 @@
+package dokter
+
+import "time"
+
+// Event progres customer request (websocket /ws/requests/:request_id)
+const (
+	RequestEventSnapshot       = "snapshot"        // status saat client connect
+	RequestEventOfferSent      = "offer_sent"      // offer dikirim ke dokter ke-n
+	RequestEventOfferTimeout   = "offer_timeout"   // dokter ke-n tidak merespon
+	RequestEventOfferDeclined  = "offer_declined"  // dokter menolak
+	RequestEventSearchingWider = "searching_wider" // radius pencarian diperluas
+	RequestEventMatched        = "matched"         // dokter menerima, service order dibuat
+	RequestEventNoDoctorFound  = "no_doctor_found" // request expired
+	RequestEventCancelled      = "cancelled"       // dibatalkan customer
+	RequestEventRedispatching  = "redispatching"   // dokter membatalkan, cari dokter pengganti
+)
+
+// publishRequestEvent kirim event progres ke semua koneksi request
+func (s *Service) publishRequestEvent(requestID int64, event string, data map[string]interface{}) {
+	msg := map[string]interface{}{
+		"event":      event,
+		"request_id": requestID,
+		"at":         time.Now(),
+	}
+	for k, v := range data {
+		msg[k] = v
+	}
+
+	s.Requests.Broadcast(requestID, msg)
+}
+
+// offersSent push offer ke mitra + kabari customer untuk setiap offer
+// yang baru aktif
+func (s *Service) offersSent(requestID int64, sent []OfferTarget) {
+	for _, o := range sent {
+		s.notifyNewOffer(o.MitraID, requestID)
+		s.pushOfferToMitra(o)
+		s.publishRequestEvent(requestID, RequestEventOfferSent, map[string]interface{}{
+			"doctor_number": o.Sequence,
+			"expired_at":    o.ExpiredAt,
+		})
+	}
+}
@@ -15,47 +15,59 @@ func RegisterRoutes(app *fiber.App, h *Handler) {
 	// CUSTOMER (PASIEN)
 	// -------------------------------
 	customer := api.Group("/customer", middleware.JWTProtected())
-	// Search dokter
+	// Quote harga (wajib sebelum search / booking)
+	customer.Post("/quotes", h.CreateQuote)
+	// Search mitra (dokter / vertical lain sesuai quote)
 	customer.Post("/doctors/search", h.SearchDoctor)
+	customer.Post("/mitra/search", h.SearchDoctor)
+	// Booking kunjungan terjadwal
+	customer.Post("/bookings", h.CreateBooking)
 	// List / history service order
 	customer.Get("/service-orders", h.GetMyServiceOrders)
 	// ✅ CURRENT / ACTIVE ORDER (INI YANG BARU)
 	customer.Get("/current-order", h.GetCustomerCurrentOrder)
 	// Cancel request (sebelum accepted)
 	customer.Post("/service-orders/:id/cancel", h.CancelOrder)
+	// Cancel service order (setelah diterima dokter, :id = service order id)
+	customer.Get("/cancel-reasons", h.GetCancelReasonsCustomer)
+	customer.Post("/orders/:id/cancel", h.CancelServiceOrderCustomer)
 	// Complete order (by user) - NEW
 	customer.Post("/service-orders/:id/complete", h.CompleteOrderUser)
+	// Bukti layanan (foto, dokumen, tanda tangan) dari mitra
+	customer.Get("/service-orders/:id/attachments", h.GetOrderAttachments)
 	// Rate doctor
 	customer.Post("/rate", h.RateDoctor)
 
 	// -------------------------------
 	// DOKTER / MITRA
+	// /api/dokter dipertahankan untuk app lama, vertical lain (perawat,
+	// fisioterapis, ...) memakai /api/mitra dengan handler yang sama
 	// -------------------------------
-	dokter := api.Group("/dokter", middleware.JWTProtected())
-	// Dokter profile
-	dokter.Post("/register", h.RegisterMitra)
-	dokter.Get("/me", h.GetMyMitraProfile)
-	// Offer flow
-	dokter.Get("/current-offer", h.GetCurrentOffer)
-	dokter.Post("/offers/:id/accept", h.AcceptOffer)
-	// ✅ CURRENT / ACTIVE SERVICE ORDER (INI YANG BARU)
-	dokter.Get("/current-order", h.GetMitraCurrentOrder)
-	// Complete service
-	dokter.Post("/transaction/:id/complete", h.CompleteOrder)
-	// Rating stats (Monthly breakdown)
-	dokter.Get("/rating-summary", h.GetRatingSummary)
-	dokter.Get("/rating-history", h.GetRatingHistory)
-	// Order stats
-	dokter.Get("/order-summary", h.GetOrderSummary)
-	dokter.Get("/order-history", h.GetOrderHistory)
+	registerMitraRoutes(api.Group("/dokter", middleware.JWTProtected()), h)
+	registerMitraRoutes(api.Group("/mitra", middleware.JWTProtected()), h)
 
-	// Earning & Withdrawal
-	dokter.Get("/balance", h.GetMitraBalance)
-	dokter.Post("/withdraw", h.Withdraw)
-	dokter.Get("/earnings", h.GetMitraEarningsHistory)
+	// -------------------------------
+	// ADMIN: REVIEW KYC MITRA
+	// -------------------------------
+	admin := api.Group("/admin", middleware.JWTProtected(), middleware.AdminOnly(h.Service.Repo.DB))
+	admin.Get("/mitra-documents", h.GetDocumentReviewQueue)
+	admin.Post("/mitra-documents/:id/review", h.ReviewDocument)
+	admin.Get("/mitra/:user_id/verification", h.GetMitraVerificationAdmin)
 
-	// 🔥 UPDATE STATUS (OTW / ARRIVED / COMPLETED)
-	dokter.Put("/service-orders/:id/status", h.UpdateServiceOrderStatus)
+	// -------------------------------
+	// FILE PRIVATE (presigned URL berumur pendek)
+	// -------------------------------
+	files := api.Group("/files", middleware.JWTProtected())
+	files.Get("/mitra-documents/:id", h.GetMitraDocumentFile)
+	files.Get("/order-attachments/:id", h.GetOrderAttachmentFile)
+	files.Get("/mitra/:user_id/photo", h.GetMitraPhotoFile)
+
+	// -------------------------------
+	// UPLOAD LANGSUNG KE STORAGE (presigned POST + finalize)
+	// -------------------------------
+	uploads := api.Group("/uploads", middleware.JWTProtected())
+	uploads.Post("/", h.CreateUploadSession)
+	uploads.Post("/:id/finalize", h.FinalizeUpload)
 
 	// ===============================
 	// WEBSOCKET (TIDAK DI DALAM JWT GROUP)
@@ -68,4 +80,81 @@ func RegisterRoutes(app *fiber.App, h *Handler) {
 		websocket.New(h.OrderStatusWS),
 	)
 
+	// progres pencarian dokter per customer request
+	ws.Get(
+		"/requests/:request_id",
+		middleware.WebSocketJWTProtected(),
+		websocket.New(h.RequestProgressWS),
+	)
+
+	for _, prefix := range []string{"/dokter", "/mitra"} {
+		// offer realtime untuk mitra (pengganti polling current-offer)
+		ws.Get(
+			prefix+"/offers",
+			middleware.WebSocketJWTProtected(),
+			websocket.New(h.MitraOfferWS),
+		)
+
+		// heartbeat lokasi mitra
+		ws.Get(
+			prefix+"/location",
+			middleware.WebSocketJWTProtected(),
+			websocket.New(h.MitraLocationWS),
+		)
+	}
+
+	// event pribadi user (request expired, dll)
+	ws.Get(
+		"/me",
+		middleware.WebSocketJWTProtected(),
+		websocket.New(h.UserWS),
+	)
+
+}
+
+// registerMitraRoutes endpoint sisi mitra, sama untuk semua vertical
+func registerMitraRoutes(mitra fiber.Router, h *Handler) {
+	// Mitra profile
+	mitra.Post("/register", h.RegisterMitra)
+	mitra.Get("/required-documents", h.GetRequiredDocuments)
+	mitra.Get("/me", h.GetMyMitraProfile)
+	mitra.Put("/me", h.UpdateMyMitraProfile)
+	mitra.Put("/me/photo", h.UpdateMyMitraPhoto)
+	// KYC: status verifikasi + upload ulang dokumen ditolak
+	mitra.Get("/verification", h.GetMyVerification)
+	mitra.Post("/documents/:doc_type", h.ReuploadDocument)
+	// Online / offline + jam kerja
+	mitra.Put("/availability", h.UpdateAvailability)
+	mitra.Get("/working-hours", h.GetWorkingHours)
+	mitra.Put("/working-hours", h.UpdateWorkingHours)
+	// Heartbeat lokasi saat online
+	mitra.Post("/location", h.UpdateLocation)
+	// Offer flow
+	mitra.Get("/current-offer", h.GetCurrentOffer)
+	mitra.Post("/offers/:id/accept", h.AcceptOffer)
+	mitra.Post("/offers/:id/reject", h.RejectOffer)
+	// ✅ CURRENT / ACTIVE SERVICE ORDER (INI YANG BARU)
+	mitra.Get("/current-order", h.GetMitraCurrentOrder)
+	// Complete service
+	mitra.Post("/transaction/:id/complete", h.CompleteOrder)
+	// Rating stats (Monthly breakdown)
+	mitra.Get("/rating-summary", h.GetRatingSummary)
+	mitra.Get("/rating-history", h.GetRatingHistory)
+	// Order stats
+	mitra.Get("/order-summary", h.GetOrderSummary)
+	mitra.Get("/order-history", h.GetOrderHistory)
+
+	// Earning & Withdrawal
+	mitra.Get("/balance", h.GetMitraBalance)
+	mitra.Post("/withdraw", h.Withdraw)
+	mitra.Get("/earnings", h.GetMitraEarningsHistory)
+
+	// 🔥 UPDATE STATUS (OTW / ARRIVED / COMPLETED)
+	mitra.Put("/service-orders/:id/status", h.UpdateServiceOrderStatus)
+	// Cancel service order yang sudah diterima
+	mitra.Get("/cancel-reasons", h.GetCancelReasonsMitra)
+	mitra.Post("/service-orders/:id/cancel", h.CancelServiceOrderMitra)
+	// Bukti layanan: lihat + tambah selama ARRIVED
+	mitra.Get("/service-orders/:id/attachments", h.GetOrderAttachments)
+	mitra.Post("/service-orders/:id/attachments", h.AddOrderAttachment)
 }
@@ -6,23 +6,35 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"teka-api/internal/models"
 	"teka-api/internal/realtime/firebase"
 	"teka-api/internal/realtime/redis"
+	"teka-api/pkg/helper"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Service struct {
-	Repo *Repository
-	Hub  *OrderHub
+	Repo     *Repository
+	Hub      *OrderHub
+	Users    *UserHub
+	Requests *RequestHub
+	Offers   *UserHub // channel offer khusus mitra
+
+	verticals *verticalCache
 }
 
-func NewService(r *Repository, hub *OrderHub) *Service {
+func NewService(r *Repository, hub *OrderHub, users *UserHub, requests *RequestHub, offers *UserHub) *Service {
 	return &Service{
-		Repo: r,
-		Hub:  hub,
+		Repo:     r,
+		Hub:      hub,
+		Users:    users,
+		Requests: requests,
+		Offers:   offers,
+
+		verticals: newVerticalCache(),
 	}
 }
 
@@ -30,7 +42,8 @@ func NewService(r *Repository, hub *OrderHub) *Service {
 func (s *Service) RegisterMitra(
 	userID int,
 	req models.CreateMitraRequest,
-	files map[string]string,
+	files map[string]helper.StoredFile,
+	licenses map[string]LicenseInfo,
 ) error {
 
 	// 1. user_mitra
@@ -54,13 +67,18 @@ func (s *Service) RegisterMitra(
 		return err
 	}
 
-	// 4. dokumen
-	for docType, url := range files {
-		if err := s.Repo.CreateDocument(userID, docType, url); err != nil {
+	// 4. dokumen (PENDING review KYC)
+	for docType, file := range files {
+		if err := s.Repo.CreateDocument(userID, docType, file, licenses[docType]); err != nil {
 			return err
 		}
 	}
 
+	// 5. status verifikasi → PENDING sampai semua dokumen disetujui admin
+	if _, err := s.refreshVerification(context.Background(), int64(userID)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -74,111 +92,105 @@ func (s *Service) SearchDoctor(
 	req SearchDoctorRequest,
 ) ([]models.DoctorSearchResult, int64, error) {
 
-	// 1️⃣ Search dokter
+	// 1️⃣ Quote harga (kategori + rincian harga dari server)
+	quote, err := s.resolveQuote(ctx, customerID, req.QuoteID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var subCatID int64
+	if quote.JobSubCategoryID != nil {
+		subCatID = *quote.JobSubCategoryID
+	}
+
+	// 2️⃣ Search dokter
+	radius, err := s.Repo.GetMaxRadius(ctx)
+	if err != nil {
+		radius = 10
+	}
+
 	doctors, err := s.Repo.SearchDoctors(
 		ctx,
-		req.JobCategoryID,
-		req.JobSubCategoryID,
+		quote.JobCategoryID,
+		subCatID,
 		req.Latitude,
 		req.Longitude,
+		radius,
+		0,
 	)
 	if err != nil {
 		return nil, 0, err
 	}
 	if len(doctors) == 0 {
-		return nil, 0, errors.New("tidak ada dokter yang tersedia")
-	}
-
-	// 2️⃣ Optional fields
-	var voucherID *int64
-	if req.VoucherID != 0 {
-		v := req.VoucherID
-		voucherID = &v
+		return nil, 0, errors.New(s.Vertical(ctx, quote.JobCategoryID).Text(CopyNoMitraAvailable))
 	}
 
-	var subCatID *int64
-	if req.JobSubCategoryID != 0 {
-		s := req.JobSubCategoryID
-		subCatID = &s
-	}
-
-	// 3️⃣ Create customer request
+	// 3️⃣ Create customer request (quote di-claim, harga dari quote)
 	requestID, err := s.Repo.CreateCustomerRequest(
 		ctx,
 		models.CustomerRequest{
-			CustomerID:       customerID,
-			JobCategoryID:    req.JobCategoryID,
-			JobSubCategoryID: subCatID,
-			Keluhan:          req.Keluhan,
-			Latitude:         req.Latitude,
-			Longitude:        req.Longitude,
-			Radius:           req.Radius,
-			Price:            req.Price,
-			VoucherID:        voucherID,
-			VoucherValue:     &req.VoucherValue,
-			PlatformFee:      req.PlatformFee,
-			THRBonus:         req.THRBonus,
+			CustomerID:   customerID,
+			Keluhan:      req.Keluhan,
+			Latitude:     req.Latitude,
+			Longitude:    req.Longitude,
+			Radius:       req.Radius,
+			SearchRadius: radius,
+			QuoteID:      &quote.ID,
 		},
 	)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// 4️⃣ Create offers
-	if err := s.Repo.CreateOffers(ctx, requestID, doctors); err != nil {
-		return nil, 0, err
+	// 4️⃣ Create offers sesuai strategi dispatch kategori
+	dispatch := s.GetDispatchConfig(ctx, quote.JobCategoryID)
+	if dispatch.Mode == DispatchFanout && len(doctors) > dispatch.BatchSize {
+		doctors = doctors[:dispatch.BatchSize]
 	}
 
-	// 5️⃣ Push notif ke mitra pertama
-	firstDoctor := doctors[0]
-	tokens, err := s.Repo.GetFCMTokensByUserID(ctx, firstDoctor.MitraID)
+	sent, err := s.Repo.CreateOffers(ctx, requestID, doctors, dispatch.BatchSize)
 	if err != nil {
-		log.Println("GetFCMTokens error:", err)
-		return doctors, requestID, nil
-	}
-
-	if len(tokens) == 0 {
-		log.Println("No FCM token for mitra:", firstDoctor.MitraID)
-		return doctors, requestID, nil
-	}
-
-	// 6️⃣ Goroutine async untuk FCM + logging
-	go func(tokens []string, requestID int64, mitraID int64) {
-		// timeout supaya gak hang
-		fcmCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		// Payload notification + data
-		results := firebase.SendFCMToTokens(
-			fcmCtx,
-			tokens,
-			"Ada Orderan Baru 🚨",
-			"Ada customer membutuhkan layanan kamu",
-			map[string]string{
-				"type":       "NEW_ORDER",
-				"request_id": strconv.FormatInt(requestID, 10),
-			},
-		)
-
-		// Simpan log ke DB per token
-		for token, err := range results {
-			status := "SENT"
-			var errStr *string
-			if err != nil {
-				status = "FAILED"
-				s := err.Error()
-				errStr = &s
-			}
+		return nil, 0, err
+	}
 
-			if dbErr := s.Repo.LogFCMResult(mitraID, token, status, errStr); dbErr != nil {
-				log.Println("❌ Failed to log FCM result:", dbErr)
-			}
-		}
-	}(tokens, requestID, firstDoctor.MitraID)
+	// 5️⃣ Push notif ke gelombang pertama
+	s.offersSent(requestID, sent)
 
 	return doctors, requestID, nil
 }
 
+// notifyNewOffer push FCM offer baru ke mitra
+func (s *Service) notifyNewOffer(mitraID, requestID int64) {
+	s.pushToUser(
+		mitraID,
+		"Ada Orderan Baru 🚨",
+		"Ada customer membutuhkan layanan kamu",
+		map[string]string{
+			"type":       "NEW_ORDER",
+			"request_id": strconv.FormatInt(requestID, 10),
+		},
+	)
+}
+
+// notifyOfferWithdrawn push FCM + websocket bahwa offer sudah tidak berlaku
+func (s *Service) notifyOfferWithdrawn(mitraID, requestID int64, reason string) {
+	s.publishOfferEvent(mitraID, OfferEventWithdrawn, map[string]interface{}{
+		"request_id": requestID,
+		"reason":     reason,
+	})
+
+	s.pushToUser(
+		mitraID,
+		"Orderan Tidak Tersedia",
+		"Orderan sudah tidak tersedia",
+		map[string]string{
+			"type":       "OFFER_WITHDRAWN",
+			"request_id": strconv.FormatInt(requestID, 10),
+			"reason":     reason,
+		},
+	)
+}
+
 // -------------------------------
 // CANCEL ORDERAN
 // -------------------------------
@@ -194,13 +206,25 @@ func (s *Service) CancelOrder(ctx context.Context, requestID, customerID int64)
 		return errors.New("not your request")
 	}
 
-	// Cek status
-	if req.Status == "matched" || req.Status == "completed" {
-		return errors.New("cannot cancel this order")
+	// Cek status: hanya sebelum diterima mitra. Setelah match pembatalan
+	// lewat CancelServiceOrder (kena biaya sesuai kebijakan)
+	if req.StatusID != RequestStatusOpen && req.StatusID != RequestStatusScheduled {
+		return errors.New("request sudah diterima mitra, batalkan lewat order")
 	}
 
 	// Cancel request + offers
-	return s.Repo.CancelCustomerRequest(ctx, requestID)
+	withdrawn, err := s.Repo.CancelCustomerRequest(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	s.publishRequestEvent(requestID, RequestEventCancelled, nil)
+
+	for _, o := range withdrawn {
+		s.notifyOfferWithdrawn(o.MitraID, requestID, "CANCELLED")
+	}
+
+	return nil
 }
 
 // START DETAIL CUSTOMER DI MITRA
@@ -240,7 +264,21 @@ func (s *Service) AcceptOffer(
 	}
 
 	// Accept offer + cancel lainnya + create service order
-	return s.Repo.AcceptOfferAndCreateOrder(ctx, o)
+	orderID, withdrawn, err := s.Repo.AcceptOfferAndCreateOrder(ctx, o)
+	if err != nil {
+		return err
+	}
+
+	s.publishRequestEvent(o.RequestID, RequestEventMatched, map[string]interface{}{
+		"order_id": orderID,
+	})
+
+	// Beri tahu dokter lain yang offer-nya masih tampil
+	for _, id := range withdrawn {
+		s.notifyOfferWithdrawn(id, o.RequestID, "TAKEN")
+	}
+
+	return nil
 }
 
 // START DETAIL DOKTER DI CUSTOMER
@@ -275,10 +313,17 @@ func (s *Service) CompleteOrder(
 	ctx context.Context,
 	mitraID int64,
 	req *models.CompleteOrderRequest,
+	attachments []OrderAttachmentFile,
 ) error {
+	for _, att := range attachments {
+		if err := checkAttachmentContentType(att.Type, att.File.ContentType); err != nil {
+			return err
+		}
+	}
+
 	data := CompleteOrderTxData{
 		Note:        req.Note,
-		Attachments: req.Attachments,
+		Attachments: attachments,
 	}
 
 	// 1️⃣ Complete order (DB transaction)
@@ -391,7 +436,71 @@ func (s *Service) RateDoctor(
 	)
 }
 
-// service/reject_offer.go
+// Alasan dokter menolak offer
+var offerRejectReasons = map[string]bool{
+	"TOO_FAR":      true, // lokasi terlalu jauh
+	"BUSY":         true, // sedang ada kegiatan lain
+	"OUT_OF_SCOPE": true, // keluhan di luar kompetensi
+	"PERSONAL":     true, // alasan pribadi
+	"OTHER":        true,
+}
+
+// RejectOffer dokter menolak offer → offer berikutnya langsung dikirim
+func (s *Service) RejectOffer(
+	ctx context.Context,
+	offerID int64,
+	mitraID int64,
+	reasonCode string,
+	note string,
+) error {
+
+	reasonCode = strings.ToUpper(strings.TrimSpace(reasonCode))
+	if reasonCode != "" && !offerRejectReasons[reasonCode] {
+		return errors.New("invalid reason_code")
+	}
+
+	requestID, jobCategoryID, err := s.Repo.GetOfferRequestInfo(ctx, offerID, mitraID)
+	if err != nil {
+		return err
+	}
+
+	dispatch := s.GetDispatchConfig(ctx, jobCategoryID)
+
+	next, exhausted, err := s.Repo.RejectOfferAndMoveNext(
+		ctx,
+		offerID,
+		mitraID,
+		reasonCode,
+		strings.TrimSpace(note),
+		dispatch.BatchSize,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🙅 Offer %d rejected by mitra %d (reason: %s)", offerID, mitraID, reasonCode)
+	s.publishRequestEvent(requestID, RequestEventOfferDeclined, nil)
+	s.handleQueueAdvance(requestID, next, exhausted)
+
+	return nil
+}
+
+// handleQueueAdvance notif mitra gelombang berikutnya setelah timeout / reject
+func (s *Service) handleQueueAdvance(requestID int64, next []OfferTarget, exhausted bool) {
+	if exhausted {
+		log.Printf("🏁 Request %d reached end of queue, expanding search radius...", requestID)
+		go s.expandSearchOrExpire(requestID)
+		return
+	}
+
+	for _, n := range next {
+		log.Printf("🚀 Distributing request %d to mitra selanjutnya: %s (%d)",
+			requestID, n.Nama, n.MitraID)
+	}
+
+	// Push notif ke mitra selanjutnya
+	s.offersSent(requestID, next)
+}
 
 // Ambil voucher aktif customer
 func (s *Service) GetActiveVoucher(ctx context.Context, userID int64) ([]models.Voucher, error) {
@@ -399,105 +508,122 @@ func (s *Service) GetActiveVoucher(ctx context.Context, userID int64) ([]models.
 }
 
 // WORKER
+
+const (
+	// offerExpiryMaxWait batas tidur scheduler, jaga-jaga notifikasi terlewat
+	offerExpiryMaxWait = 30 * time.Second
+	// offerExpiryRetry jeda untuk offer yang sudah lewat tapi belum bisa
+	// diproses (request sedang dikunci transaksi lain)
+	offerExpiryRetry = 500 * time.Millisecond
+	// offerListenRetry jeda reconnect LISTEN jika koneksi putus
+	offerListenRetry = 5 * time.Second
+)
+
+// RunOfferTimeoutWorker scheduler expiry offer: timer di-set tepat ke
+// expired_at offer waiting terdekat (disimpan di request_mitra_offers, jadi
+// aman saat restart) dan dihitung ulang setiap ada offer baru via NOTIFY.
 func (s *Service) RunOfferTimeoutWorker(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second) // cek tiap 5 detik
-	defer ticker.Stop()
+	wake := make(chan struct{}, 1)
+	go s.listenOfferExpiry(ctx, wake)
+
+	timer := time.NewTimer(0) // proses offer yang sudah lewat saat start
+	defer timer.Stop()
 
 	log.Println("👷 Offer Timeout Worker started")
 
 	for {
 		select {
-		case <-ticker.C:
-			// 1. Ambil timeout dari global parameter (default 60)
-			timeoutSeconds := 60
-			val, err := s.Repo.GetGlobalParameter(ctx, "OFFER_TIMEOUT_SECONDS")
-			if err == nil && val != "" {
-				if t, err := strconv.Atoi(val); err == nil {
-					timeoutSeconds = t
-				}
-			}
-
-			// 2. Ambil penawaran yang sudah expired
-			offers, err := s.Repo.GetExpiredOffers(ctx, timeoutSeconds)
-			if err != nil {
-				log.Println("❌ timeout worker error:", err)
-				continue
-			}
-
-			if len(offers) > 0 {
-				log.Printf("🔍 Worker found %d expired offers", len(offers))
-			}
+		case <-timer.C:
+			s.processExpiredOffers(ctx)
 
-			for _, offer := range offers {
-				log.Printf("⏰ Offer %d expired for request %d (Dokter: %s), moving to next sequence...",
-					offer.ID, offer.RequestID, offer.MitraName)
+		case <-wake:
+			// ada offer baru dijadwalkan → hitung ulang timer
 
-				nextMitraID, nextMitraName, err := s.Repo.TimeoutAndMoveNext(
-					ctx,
-					offer.RequestID,
-					offer.Sequence,
-				)
-				if err != nil {
-					log.Println("❌ process timeout error:", err)
-					continue
-				}
+		case <-ctx.Done():
+			log.Println("👷 Offer Timeout Worker stopped")
+			return
+		}
 
-				if nextMitraID != 0 {
-					log.Printf("🚀 Distributing request %d to Dokter selanjutnya: %s (%d)",
-						offer.RequestID, nextMitraName, nextMitraID)
-					// Push notif ke mitra selanjutnya
-					tokens, err := s.Repo.GetFCMTokensByUserID(ctx, nextMitraID)
-					if err != nil {
-						log.Println("❌ FCM Error (worker):", err)
-						continue
-					}
+		timer.Reset(s.nextOfferExpiryWait(ctx))
+	}
+}
 
-					if len(tokens) > 0 {
-						go func(tks []string, mID int64, rID int64) {
-							fcmCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-							defer cancel()
-
-							results := firebase.SendFCMToTokens(
-								fcmCtx,
-								tks,
-								"Ada Orderan Baru 🚨",
-								"Ada customer membutuhkan layanan kamu",
-								map[string]string{
-									"type":       "NEW_ORDER",
-									"request_id": strconv.FormatInt(rID, 10),
-								},
-							)
-
-							for token, err := range results {
-								status := "SENT"
-								var errStr *string
-								if err != nil {
-									status = "FAILED"
-									s := err.Error()
-									errStr = &s
-								}
-								_ = s.Repo.LogFCMResult(mID, token, status, errStr)
-							}
-						}(tokens, nextMitraID, offer.RequestID)
-					}
-				} else {
-					log.Printf("🏁 Request %d reached end of queue or no more offers.", offer.RequestID)
-				}
-			}
+// listenOfferExpiry jaga koneksi LISTEN tetap hidup selama worker jalan
+func (s *Service) listenOfferExpiry(ctx context.Context, wake chan<- struct{}) {
+	for {
+		if err := s.Repo.ListenOfferExpiry(ctx, wake); err != nil {
+			log.Println("⚠️ offer expiry listener error:", err)
+		}
 
+		select {
 		case <-ctx.Done():
-			log.Println("👷 Offer Timeout Worker stopped")
 			return
+		case <-time.After(offerListenRetry):
 		}
 	}
 }
 
-// allowedTransitions: status order yang boleh diubah
-var allowedTransitions = map[int16][]int16{
-	1: {2, 5},
-	2: {3, 5, 6},
-	3: {4, 5, 6},
-	4: {6},
+// nextOfferExpiryWait durasi sampai offer waiting terdekat expired
+func (s *Service) nextOfferExpiryWait(ctx context.Context) time.Duration {
+	next, err := s.Repo.NextOfferExpiryIn(ctx)
+	if err != nil {
+		log.Println("❌ next offer expiry error:", err)
+		return offerExpiryMaxWait
+	}
+	if next == nil || *next > offerExpiryMaxWait {
+		return offerExpiryMaxWait
+	}
+	if *next <= 0 {
+		return offerExpiryRetry
+	}
+	return *next
+}
+
+// processExpiredOffers timeout semua offer yang sudah lewat expired_at
+// lalu lanjutkan antrian masing-masing request
+func (s *Service) processExpiredOffers(ctx context.Context) {
+	offers, err := s.Repo.GetExpiredOffers(ctx)
+	if err != nil {
+		log.Println("❌ timeout worker error:", err)
+		return
+	}
+
+	if len(offers) > 0 {
+		log.Printf("🔍 Worker found %d expired offers", len(offers))
+	}
+
+	for _, offer := range offers {
+		log.Printf("⏰ Offer %d expired for request %d (mitra: %s), moving to next sequence...",
+			offer.ID, offer.RequestID, offer.MitraName)
+
+		dispatch := s.GetDispatchConfig(ctx, offer.JobCategoryID)
+
+		next, exhausted, timedOut, err := s.Repo.TimeoutAndMoveNext(
+			ctx,
+			offer.RequestID,
+			offer.Sequence,
+			dispatch.BatchSize,
+		)
+		if err != nil {
+			log.Println("❌ process timeout error:", err)
+			continue
+		}
+
+		if !timedOut {
+			// dikunci proses lain / sudah diterima atau ditolak: bukan timeout
+			continue
+		}
+
+		s.publishRequestEvent(offer.RequestID, RequestEventOfferTimeout, map[string]interface{}{
+			"doctor_number": offer.Sequence,
+		})
+		s.publishOfferEvent(offer.MitraID, OfferEventExpired, map[string]interface{}{
+			"offer_id":   offer.ID,
+			"request_id": offer.RequestID,
+		})
+
+		s.handleQueueAdvance(offer.RequestID, next, exhausted)
+	}
 }
 
 // UpdateServiceOrderStatus: mitra update status order
@@ -516,14 +642,17 @@ func (s *Service) UpdateServiceOrderStatus(
 		return errors.New("order already finalized")
 	}
 
-	valid := false
-	for _, s := range allowedTransitions[currentStatus] {
-		if s == newStatusID {
-			valid = true
-			break
-		}
+	if newStatusID == 5 {
+		return errors.New("gunakan endpoint cancel untuk membatalkan order")
 	}
-	if !valid {
+	if newStatusID == 6 {
+		// selesai hanya setelah pembayaran (voucher, referral, saldo mitra)
+		return errors.New("order selesai setelah customer menyelesaikan pembayaran")
+	}
+
+	// alur status per vertical (job_categories.status_flow)
+	vertical := s.orderVertical(ctx, int64(orderID))
+	if !vertical.CanTransition(currentStatus, newStatusID) {
 		return errors.New("invalid status transition")
 	}
 
@@ -556,7 +685,7 @@ func (s *Service) UpdateServiceOrderStatus(
 
 	// 🔔 NOTIFIKASI FCM
 	if newStatusID == 2 || newStatusID == 3 {
-		go func(oID int, sID int16) {
+		go func(oID int, sID int16, vertical Vertical) {
 			fcmCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 
@@ -572,11 +701,11 @@ func (s *Service) UpdateServiceOrderStatus(
 				return
 			}
 
-			title := "Dokter OTW 🏎️"
-			body := fmt.Sprintf("Dokter sedang menuju lokasi untuk order %s", orderNo)
+			title := vertical.Text(CopyStatusOTWTitle, "order", orderNo)
+			body := vertical.Text(CopyStatusOTWBody, "order", orderNo)
 			if sID == 3 {
-				title = "Dokter Sampai 🏁"
-				body = fmt.Sprintf("Dokter sudah sampai di lokasi untuk order %s", orderNo)
+				title = vertical.Text(CopyStatusArrivedTitle, "order", orderNo)
+				body = vertical.Text(CopyStatusArrivedBody, "order", orderNo)
 			}
 
 			results := firebase.SendFCMToTokens(fcmCtx, tokens, title, body, map[string]string{
@@ -595,7 +724,7 @@ func (s *Service) UpdateServiceOrderStatus(
 				}
 				_ = s.Repo.LogFCMResult(customerID, token, status, errStr)
 			}
-		}(orderID, newStatusID)
+		}(orderID, newStatusID, vertical)
 	}
 
 	return nil
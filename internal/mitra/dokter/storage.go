@@ -0,0 +1,447 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/helper"
+	"teka-api/pkg/middleware"
+	"teka-api/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// orderAttachmentBucket bucket private lampiran / bukti layanan order
+func orderAttachmentBucket() string {
+	return utils.AttachmentBucket()
+}
+
+// mitraBucket bucket private dokumen KYC & foto profil mitra
+func mitraBucket() string {
+	return os.Getenv("S3_BUCKET")
+}
+
+// presign presigned GET URL untuk object key (atau URL publik lama);
+// gagal presign → string kosong supaya response tetap terkirim
+func (h *Handler) presign(bucket, stored string) string {
+	if stored == "" {
+		return ""
+	}
+	u, err := helper.PresignGetURL(h.MinioClient, bucket, helper.ObjectKeyFromURL(stored, bucket), helper.PresignTTL())
+	if err != nil {
+		log.Printf("⚠️ presign %s/%s: %v", bucket, stored, err)
+		return ""
+	}
+	return u
+}
+
+// presignDocuments isi FileURL & ThumbURL tiap dokumen dengan presigned URL
+func (h *Handler) presignDocuments(docs []models.MitraDocument) {
+	for i := range docs {
+		h.presignDocument(&docs[i])
+	}
+}
+
+func (h *Handler) presignDocument(doc *models.MitraDocument) {
+	doc.FileURL = h.presign(mitraBucket(), doc.FileKey)
+	if doc.ThumbKey != nil {
+		doc.ThumbURL = h.presign(mitraBucket(), *doc.ThumbKey)
+	}
+}
+
+// presignProfile isi foto & dokumen profil mitra dengan presigned URL
+func (h *Handler) presignProfile(p *models.MitraProfile) {
+	if p.PhotoKey != nil {
+		if u := h.presign(mitraBucket(), *p.PhotoKey); u != "" {
+			p.PhotoURL = &u
+		}
+	}
+	if p.PhotoThumbKey != nil {
+		if u := h.presign(mitraBucket(), *p.PhotoThumbKey); u != "" {
+			p.PhotoThumbURL = &u
+		}
+	}
+	h.presignDocuments(p.Documents)
+}
+
+// presignAttachments isi FileURL & ThumbURL tiap lampiran order
+func (h *Handler) presignAttachments(list []models.OrderAttachment) {
+	for i := range list {
+		list[i].FileURL = h.presign(orderAttachmentBucket(), list[i].FileKey)
+		if list[i].ThumbKey != nil {
+			list[i].ThumbURL = h.presign(orderAttachmentBucket(), *list[i].ThumbKey)
+		}
+	}
+}
+
+// uploadErrorStatus file yang tidak lolos validasi → 400, selain itu 500
+func uploadErrorStatus(err error) int {
+	if errors.Is(err, helper.ErrUnsupportedFile) ||
+		errors.Is(err, helper.ErrFileTooLarge) ||
+		errors.Is(err, helper.ErrCorruptFile) {
+		return 400
+	}
+	return 500
+}
+
+// removeStoredFile hapus object + thumbnail yang tidak jadi dipakai
+func (h *Handler) removeStoredFile(bucket string, file helper.StoredFile) {
+	for _, key := range []string{file.Key, file.ThumbKey} {
+		if key == "" {
+			continue
+		}
+		if err := helper.RemoveObjectFromMinio(h.MinioClient, bucket, key); err != nil {
+			log.Printf("⚠️ remove unused object %s: %v", key, err)
+		}
+	}
+}
+
+// fileURLResponse response standar presigned URL
+func fileURLResponse(c *fiber.Ctx, url string) error {
+	if url == "" {
+		return c.Status(503).JSON(fiber.Map{"error": "file tidak dapat diakses saat ini"})
+	}
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"url":        url,
+			"expires_at": time.Now().Add(helper.PresignTTL()),
+		},
+	})
+}
+
+// fileAccessError map error akses file ke status HTTP
+func fileAccessError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrFileForbidden):
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "file tidak ditemukan"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
+
+// GetMitraDocumentFile presigned URL dokumen KYC (pemilik / admin)
+func (h *Handler) GetMitraDocumentFile(c *fiber.Ctx) error {
+	viewerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	docID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid document id"})
+	}
+
+	doc, err := h.Service.GetDocumentForViewer(c.Context(), int64(viewerID), docID)
+	if err != nil {
+		return fileAccessError(c, err)
+	}
+
+	return fileURLResponse(c, h.presign(mitraBucket(), doc.FileKey))
+}
+
+// GetOrderAttachmentFile presigned URL lampiran order (customer / mitra order / admin)
+func (h *Handler) GetOrderAttachmentFile(c *fiber.Ctx) error {
+	viewerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	attID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid attachment id"})
+	}
+
+	att, err := h.Service.GetAttachmentForViewer(c.Context(), int64(viewerID), attID)
+	if err != nil {
+		return fileAccessError(c, err)
+	}
+
+	return fileURLResponse(c, h.presign(orderAttachmentBucket(), att.URL))
+}
+
+// GetOrderAttachments daftar bukti layanan order (customer / mitra order, admin)
+func (h *Handler) GetOrderAttachments(c *fiber.Ctx) error {
+	viewerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	list, err := h.Service.ListOrderAttachments(c.Context(), int64(viewerID), orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "order tidak ditemukan"})
+		}
+		return fileAccessError(c, err)
+	}
+	h.presignAttachments(list)
+
+	return c.JSON(fiber.Map{
+		"data":       list,
+		"expires_at": time.Now().Add(helper.PresignTTL()),
+	})
+}
+
+// AddOrderAttachment mitra upload bukti layanan (photo / document /
+// signature) selama status ARRIVED. Multipart: file + type.
+func (h *Handler) AddOrderAttachment(c *fiber.Ctx) error {
+	mitraID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if h.MinioClient == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "storage tidak tersedia"})
+	}
+
+	orderID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	attachmentType, err := normalizeAttachmentType(c.FormValue("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "file wajib diisi"})
+	}
+	if fileHeader.Size > maxUploadBytes {
+		return c.Status(400).JSON(fiber.Map{"error": "ukuran file maksimal 10MB"})
+	}
+
+	stored, err := helper.UploadFileToMinio(
+		h.MinioClient,
+		orderAttachmentBucket(),
+		fmt.Sprintf("orders/%d", orderID),
+		fileHeader,
+		maxUploadBytes,
+	)
+	if err != nil {
+		return c.Status(uploadErrorStatus(err)).JSON(fiber.Map{"error": fmt.Sprintf("upload %s failed: %v", attachmentType, err)})
+	}
+
+	att, err := h.Service.AddOrderAttachment(c.Context(), int64(mitraID), orderID, attachmentType, stored)
+	if err != nil {
+		h.removeStoredFile(orderAttachmentBucket(), stored)
+		if errors.Is(err, ErrFileForbidden) {
+			return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	list := []models.OrderAttachment{att}
+	h.presignAttachments(list)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "attachment added",
+		"data":    list[0],
+	})
+}
+
+// GetMitraPhotoFile presigned URL foto profil mitra (mitra sendiri,
+// customer yang punya order dengan mitra, admin)
+func (h *Handler) GetMitraPhotoFile(c *fiber.Ctx) error {
+	viewerID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	mitraID, err := strconv.ParseInt(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid user_id"})
+	}
+
+	key, err := h.Service.GetMitraPhotoForViewer(c.Context(), int64(viewerID), mitraID)
+	if err != nil {
+		return fileAccessError(c, err)
+	}
+	if key == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "foto belum ada"})
+	}
+
+	return fileURLResponse(c, h.presign(mitraBucket(), *key))
+}
+
+// CreateUploadSession terbitkan presigned POST policy untuk upload langsung
+// ke storage. Client kirim multipart POST ke upload_url dengan semua fields
+// lalu field "file" paling akhir.
+func (h *Handler) CreateUploadSession(c *fiber.Ctx) error {
+	userID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if h.MinioClient == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "storage tidak tersedia"})
+	}
+
+	var body models.CreateUploadSessionRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	sess, err := h.Service.CreateUploadSession(c.Context(), int64(userID), body)
+	if err != nil {
+		if errors.Is(err, ErrFileForbidden) {
+			return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	uploadURL, fields, err := helper.PresignPostPolicy(
+		h.MinioClient,
+		sess.Bucket,
+		sess.ObjectKey,
+		sess.ContentType,
+		sess.DeclaredSize,
+		time.Until(sess.ExpiresAt),
+	)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"data": fiber.Map{
+			"upload_id":  sess.ID,
+			"upload_url": uploadURL,
+			"method":     "POST",
+			"fields":     fields,
+			"max_size":   sess.DeclaredSize,
+			"expires_at": sess.ExpiresAt,
+		},
+	})
+}
+
+// RunUploadSessionExpiryWorker hapus upload yang ditinggalkan: sesi PENDING
+// kedaluwarsa ditandai FAILED lalu object + thumbnail-nya dihapus. Jalan di
+// handler karena butuh MinIO client.
+func (h *Handler) RunUploadSessionExpiryWorker(ctx context.Context) {
+	ticker := time.NewTicker(uploadSessionExpiryInterval)
+	defer ticker.Stop()
+
+	log.Println("👷 Upload Session Expiry Worker started")
+
+	h.expireUploadSessions(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👷 Upload Session Expiry Worker stopped")
+			return
+		case <-ticker.C:
+			h.expireUploadSessions(ctx)
+		}
+	}
+}
+
+func (h *Handler) expireUploadSessions(ctx context.Context) {
+	for {
+		list, err := h.Service.ExpireUploadSessions(ctx)
+		if err != nil {
+			log.Println("❌ upload session expiry worker error:", err)
+			return
+		}
+
+		for _, sess := range list {
+			log.Printf("🧹 Upload session %d expired, removing %s", sess.ID, sess.ObjectKey)
+			if h.MinioClient != nil {
+				h.removeStoredFile(sess.Bucket, helper.StoredFile{
+					Key:      sess.ObjectKey,
+					ThumbKey: helper.ThumbnailKey(sess.ObjectKey),
+				})
+			}
+		}
+
+		if len(list) < uploadSessionExpiryBatch {
+			return
+		}
+	}
+}
+
+// FinalizeUpload verifikasi object hasil presigned POST lalu tempel ke
+// dokumen mitra / lampiran order / pesan chat
+func (h *Handler) FinalizeUpload(c *fiber.Ctx) error {
+	userID, err := middleware.UserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if h.MinioClient == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "storage tidak tersedia"})
+	}
+
+	sessionID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid upload id"})
+	}
+
+	var body models.FinalizeUploadRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+		}
+	}
+
+	sess, err := h.Service.GetPendingUploadSession(c.Context(), int64(userID), sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "upload session tidak ditemukan"})
+		}
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	size, err := helper.StatObjectSize(h.MinioClient, sess.Bucket, sess.ObjectKey)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// baca & proses isi object hanya jika ukurannya masih dalam batas
+	var (
+		processed  *helper.ProcessedFile
+		processErr error
+	)
+	if size > 0 && size <= maxUploadBytes {
+		data, err := helper.ReadObject(h.MinioClient, sess.Bucket, sess.ObjectKey, maxUploadBytes)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		processed, processErr = helper.ProcessMedia(data, maxUploadBytes)
+	}
+
+	store := func(p *helper.ProcessedFile) (helper.StoredFile, error) {
+		return helper.PutProcessedFile(h.MinioClient, sess.Bucket, sess.ObjectKey, p)
+	}
+
+	result, err := h.Service.FinalizeUpload(c.Context(), int64(userID), sess, size, processed, processErr, store, body)
+	if err != nil {
+		if errors.Is(err, ErrUploadRejected) {
+			h.removeStoredFile(sess.Bucket, helper.StoredFile{
+				Key:      sess.ObjectKey,
+				ThumbKey: helper.ThumbnailKey(sess.ObjectKey),
+			})
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if result.Verification != nil {
+		h.presignDocuments(result.Verification.Documents)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "upload finalized",
+		"data":    result,
+	})
+}
@@ -0,0 +1,363 @@
+package dokter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/internal/realtime/ws"
+	"teka-api/pkg/helper"
+
+	"gorm.io/gorm"
+)
+
+// Tujuan upload session
+const (
+	UploadPurposeMitraDocument   = "MITRA_DOCUMENT"
+	UploadPurposeOrderAttachment = "ORDER_ATTACHMENT"
+	UploadPurposeChatMessage     = "CHAT_MESSAGE"
+)
+
+// Status upload session
+const (
+	UploadStatusPending   = "PENDING"
+	UploadStatusFinalized = "FINALIZED"
+	UploadStatusFailed    = "FAILED"
+)
+
+const (
+	uploadSessionTTL = 15 * time.Minute
+	maxUploadBytes   = 10 * 1024 * 1024
+
+	// sesi PENDING dianggap ditinggalkan setelah expires_at + grace
+	uploadSessionExpiryGrace    = 5 * time.Minute
+	uploadSessionExpiryInterval = 10 * time.Minute
+	uploadSessionExpiryBatch    = 200
+)
+
+// content type yang diterima → ekstensi object
+var uploadContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// ErrUploadRejected object di storage tidak lolos verifikasi finalize;
+// sesi ditandai FAILED dan object dihapus
+var ErrUploadRejected = errors.New("file upload ditolak")
+
+// UploadResult hasil finalize sesuai tujuan upload
+type UploadResult struct {
+	Session      models.UploadSession      `json:"session"`
+	Verification *models.MitraVerification `json:"verification,omitempty"`
+	AttachmentID *int64                    `json:"attachment_id,omitempty"`
+	ChatMessage  *models.ChatMessage       `json:"chat_message,omitempty"`
+}
+
+// CreateUploadSession validasi tujuan & file lalu simpan sesi upload.
+// Presigned POST policy dibuat handler dari bucket + object key sesi.
+func (s *Service) CreateUploadSession(
+	ctx context.Context,
+	userID int64,
+	req models.CreateUploadSessionRequest,
+) (models.UploadSession, error) {
+
+	purpose := strings.ToUpper(strings.TrimSpace(req.Purpose))
+	contentType := strings.ToLower(strings.TrimSpace(req.ContentType))
+
+	ext, ok := uploadContentTypes[contentType]
+	if !ok {
+		return models.UploadSession{}, errors.New("content_type harus image/jpeg, image/png, image/webp atau application/pdf")
+	}
+	if req.Size <= 0 || req.Size > maxUploadBytes {
+		return models.UploadSession{}, fmt.Errorf("size harus 1 - %d byte", maxUploadBytes)
+	}
+
+	sess := models.UploadSession{
+		UserID:       userID,
+		Purpose:      purpose,
+		ContentType:  contentType,
+		DeclaredSize: req.Size,
+		ExpiresAt:    time.Now().Add(uploadSessionTTL),
+	}
+	fileName := helper.GenerateRandomFileName("file" + ext)
+
+	switch purpose {
+	case UploadPurposeMitraDocument:
+		docType := strings.ToLower(strings.TrimSpace(req.DocType))
+		if docType == "" {
+			return models.UploadSession{}, errors.New("doc_type wajib diisi")
+		}
+		if err := s.checkReupload(ctx, userID, docType); err != nil {
+			return models.UploadSession{}, err
+		}
+		sess.DocType = &docType
+		sess.Bucket = mitraBucket()
+		sess.ObjectKey = fmt.Sprintf("user-%d/documents/%s/%s", userID, docType, fileName)
+
+	case UploadPurposeOrderAttachment, UploadPurposeChatMessage:
+		if req.OrderID == 0 {
+			return models.UploadSession{}, errors.New("order_id wajib diisi")
+		}
+		if err := s.checkOrderUpload(ctx, userID, purpose, req.OrderID); err != nil {
+			return models.UploadSession{}, err
+		}
+		orderID := req.OrderID
+		sess.OrderID = &orderID
+		sess.Bucket = orderAttachmentBucket()
+		if purpose == UploadPurposeChatMessage {
+			sess.ObjectKey = fmt.Sprintf("orders/%d/chat/%s", orderID, fileName)
+		} else {
+			attachmentType, err := normalizeAttachmentType(req.AttachmentType)
+			if err != nil {
+				return models.UploadSession{}, err
+			}
+			if err := checkAttachmentContentType(attachmentType, contentType); err != nil {
+				return models.UploadSession{}, err
+			}
+			sess.AttachmentType = &attachmentType
+			sess.ObjectKey = fmt.Sprintf("orders/%d/%s", orderID, fileName)
+		}
+
+	default:
+		return models.UploadSession{}, errors.New("purpose harus MITRA_DOCUMENT, ORDER_ATTACHMENT atau CHAT_MESSAGE")
+	}
+
+	return s.Repo.CreateUploadSession(ctx, sess)
+}
+
+// checkOrderUpload lampiran order hanya oleh mitra saat ARRIVED, lampiran
+// chat oleh customer / mitra selama order masih berjalan
+func (s *Service) checkOrderUpload(ctx context.Context, userID int64, purpose string, orderID int64) error {
+	order, err := s.Repo.GetOrderParticipants(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("order tidak ditemukan")
+		}
+		return err
+	}
+
+	if purpose == UploadPurposeOrderAttachment {
+		if order.MitraID != userID {
+			return ErrFileForbidden
+		}
+		if order.StatusID != 3 { // ARRIVED
+			return errors.New("lampiran hanya bisa ditambahkan saat mitra sudah tiba")
+		}
+		return nil
+	}
+
+	if order.CustomerID != userID && order.MitraID != userID {
+		return ErrFileForbidden
+	}
+	if order.StatusID < 1 || order.StatusID > 4 {
+		return errors.New("chat order sudah ditutup")
+	}
+	return nil
+}
+
+// GetPendingUploadSession sesi milik user yang masih bisa di-finalize
+func (s *Service) GetPendingUploadSession(ctx context.Context, userID, sessionID int64) (models.UploadSession, error) {
+	sess, err := s.Repo.GetUploadSession(ctx, userID, sessionID)
+	if err != nil {
+		return sess, err
+	}
+	if sess.Status != UploadStatusPending {
+		return sess, fmt.Errorf("upload session sudah %s", sess.Status)
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		return sess, errors.New("upload session sudah kedaluwarsa")
+	}
+	return sess, nil
+}
+
+// FinalizeUpload verifikasi object yang sudah diupload client (ada, ukuran
+// sesuai, isi valid & sesuai content type), simpan versi yang sudah
+// dibersihkan lewat store lalu tempel ke tujuannya.
+// objectSize < 0 berarti object tidak ditemukan di storage.
+func (s *Service) FinalizeUpload(
+	ctx context.Context,
+	userID int64,
+	sess models.UploadSession,
+	objectSize int64,
+	processed *helper.ProcessedFile,
+	processErr error,
+	store func(*helper.ProcessedFile) (helper.StoredFile, error),
+	req models.FinalizeUploadRequest,
+) (UploadResult, error) {
+
+	// input finalize salah → sesi tetap PENDING, client boleh coba lagi
+	var license LicenseInfo
+	if sess.Purpose == UploadPurposeMitraDocument {
+		var err error
+		if license, err = s.ParseLicense(ctx, *sess.DocType, req.LicenseNumber, req.ExpiresAt); err != nil {
+			return UploadResult{}, err
+		}
+	}
+	if objectSize < 0 {
+		return UploadResult{}, errors.New("file belum diupload ke storage")
+	}
+
+	// verifikasi object
+	if reason := verifyUploadedObject(sess, objectSize, processed, processErr); reason != "" {
+		return UploadResult{}, s.rejectUpload(ctx, sess, objectSize, reason)
+	}
+
+	// cek ulang tujuan, status bisa berubah sejak sesi dibuat
+	var err error
+	if sess.Purpose == UploadPurposeMitraDocument {
+		err = s.checkReupload(ctx, userID, *sess.DocType)
+	} else {
+		err = s.checkOrderUpload(ctx, userID, sess.Purpose, *sess.OrderID)
+	}
+	if err != nil {
+		return UploadResult{}, s.rejectUpload(ctx, sess, objectSize, err.Error())
+	}
+
+	// timpa object asli dengan versi tanpa metadata + thumbnail
+	file, err := store(processed)
+	if err != nil {
+		return UploadResult{}, err
+	}
+
+	var attachmentID *int64
+	claimed, err := s.Repo.FinalizeUploadSession(ctx, sess.ID, objectSize, func(tx *gorm.DB) (*int64, error) {
+		switch sess.Purpose {
+		case UploadPurposeMitraDocument:
+			return nil, createDocumentTx(tx, int(userID), *sess.DocType, file, license)
+		case UploadPurposeOrderAttachment:
+			id, err := InsertOrderAttachmentTx(tx, *sess.OrderID, sessionAttachmentType(sess), file, userID)
+			attachmentID = &id
+			return attachmentID, err
+		default:
+			id, err := InsertOrderAttachmentTx(tx, *sess.OrderID, AttachmentTypeChat, file, userID)
+			attachmentID = &id
+			return attachmentID, err
+		}
+	})
+	if err != nil {
+		return UploadResult{}, err
+	}
+	if !claimed {
+		return UploadResult{}, errors.New("upload session sudah diproses")
+	}
+
+	log.Printf("📎 Upload session %d (%s) finalized by user %d", sess.ID, sess.Purpose, userID)
+
+	result := UploadResult{AttachmentID: attachmentID}
+
+	switch sess.Purpose {
+	case UploadPurposeMitraDocument:
+		if _, err := s.refreshVerification(ctx, userID); err != nil {
+			log.Printf("❌ refresh verification mitra %d: %v", userID, err)
+		}
+		v, err := s.GetVerification(ctx, userID)
+		if err != nil {
+			return UploadResult{}, err
+		}
+		result.Verification = &v
+
+	case UploadPurposeOrderAttachment:
+		order, err := s.Repo.GetOrderParticipants(ctx, *sess.OrderID)
+		if err != nil {
+			log.Printf("❌ notify attachment order %d: %v", *sess.OrderID, err)
+			break
+		}
+		s.notifyOrderAttachment(order.CustomerID, *sess.OrderID, *attachmentID, sessionAttachmentType(sess))
+
+	case UploadPurposeChatMessage:
+		msg, err := s.postChatAttachment(ctx, userID, *sess.OrderID, *attachmentID, sess.ContentType, req.Message)
+		if err != nil {
+			return UploadResult{}, err
+		}
+		result.ChatMessage = &msg
+	}
+
+	if result.Session, err = s.Repo.GetUploadSession(ctx, userID, sess.ID); err != nil {
+		return UploadResult{}, err
+	}
+	return result, nil
+}
+
+// sessionAttachmentType tipe lampiran sesi ORDER_ATTACHMENT, sesi lama → photo
+func sessionAttachmentType(sess models.UploadSession) string {
+	if sess.AttachmentType == nil || *sess.AttachmentType == "" {
+		return AttachmentTypePhoto
+	}
+	return *sess.AttachmentType
+}
+
+// verifyUploadedObject return alasan penolakan, kosong jika lolos
+func verifyUploadedObject(
+	sess models.UploadSession,
+	objectSize int64,
+	processed *helper.ProcessedFile,
+	processErr error,
+) string {
+	if objectSize != sess.DeclaredSize {
+		return fmt.Sprintf("ukuran file %d byte tidak sesuai (%d byte)", objectSize, sess.DeclaredSize)
+	}
+	if objectSize > maxUploadBytes {
+		return "ukuran file melebihi batas"
+	}
+	if processErr != nil {
+		return processErr.Error()
+	}
+	if processed == nil {
+		return "file kosong"
+	}
+	if processed.ContentType != sess.ContentType {
+		return fmt.Sprintf("isi file (%s) tidak sesuai content type %s", processed.ContentType, sess.ContentType)
+	}
+	return ""
+}
+
+// ExpireUploadSessions tandai sesi upload yang ditinggalkan sebagai FAILED;
+// handler menghapus object-nya dari storage
+func (s *Service) ExpireUploadSessions(ctx context.Context) ([]models.UploadSession, error) {
+	return s.Repo.ExpireUploadSessions(ctx, uploadSessionExpiryGrace, uploadSessionExpiryBatch)
+}
+
+// rejectUpload tandai sesi FAILED; handler menghapus object dari storage
+func (s *Service) rejectUpload(ctx context.Context, sess models.UploadSession, objectSize int64, reason string) error {
+	if err := s.Repo.FailUploadSession(ctx, sess.ID, &objectSize, reason); err != nil {
+		log.Printf("❌ fail upload session %d: %v", sess.ID, err)
+	}
+	return fmt.Errorf("%w: %s", ErrUploadRejected, reason)
+}
+
+// postChatAttachment kirim pesan chat berlampiran ke room order
+func (s *Service) postChatAttachment(
+	ctx context.Context,
+	userID, orderID, attachmentID int64,
+	contentType, message string,
+) (models.ChatMessage, error) {
+
+	order, err := s.Repo.GetOrderParticipants(ctx, orderID)
+	if err != nil {
+		return models.ChatMessage{}, err
+	}
+
+	senderType := "customer"
+	if userID == order.MitraID {
+		senderType = "mitra"
+	}
+
+	msg := models.ChatMessage{
+		OrderID:               orderID,
+		SenderID:              userID,
+		SenderType:            senderType,
+		Message:               strings.TrimSpace(message),
+		CreatedAt:             time.Now(),
+		AttachmentID:          &attachmentID,
+		AttachmentContentType: contentType,
+	}
+	ws.PostChatMessage(msg)
+
+	return msg, nil
+}
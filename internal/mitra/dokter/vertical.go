@@ -0,0 +1,250 @@
+package dokter
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vertical konfigurasi layanan per job category (dokter, perawat,
+// fisioterapis, ...). Engine dispatch / order / earnings sama; yang beda
+// hanya sebutan mitra, prefix nomor order, dokumen wajib, alur status dan
+// copy notifikasi.
+type Vertical struct {
+	JobCategoryID     int64
+	Name              string
+	OrderPrefix       string
+	MitraLabel        string
+	RequiredDocuments []string
+	StatusFlow        map[int16][]int16
+	Copy              map[string]string
+}
+
+// Key copy notifikasi (job_categories.notification_copy).
+// Placeholder: {mitra} sebutan mitra, {order} nomor order, {time} jam.
+const (
+	CopyStatusOTWTitle       = "status_otw_title"
+	CopyStatusOTWBody        = "status_otw_body"
+	CopyStatusArrivedTitle   = "status_arrived_title"
+	CopyStatusArrivedBody    = "status_arrived_body"
+	CopyNotFoundTitle        = "not_found_title"
+	CopyNotFoundBody         = "not_found_body"
+	CopyNoMitraAvailable     = "no_mitra_available"
+	CopyMitraCancelledTitle  = "mitra_cancelled_title"
+	CopyMitraCancelledBody   = "mitra_cancelled_body"
+	CopyRedispatchSuffix     = "redispatch_suffix"
+	CopyBookingReminderBody  = "booking_reminder_body"
+	CopyBookingReminderMitra = "booking_reminder_mitra"
+)
+
+var defaultVerticalCopy = map[string]string{
+	CopyStatusOTWTitle:       "{mitra} OTW 🏎️",
+	CopyStatusOTWBody:        "{mitra} sedang menuju lokasi untuk order {order}",
+	CopyStatusArrivedTitle:   "{mitra} Sampai 🏁",
+	CopyStatusArrivedBody:    "{mitra} sudah sampai di lokasi untuk order {order}",
+	CopyNotFoundTitle:        "{mitra} Tidak Ditemukan",
+	CopyNotFoundBody:         "Maaf, belum ada {mitra_lower} yang tersedia di sekitar kamu. Silakan coba lagi nanti.",
+	CopyNoMitraAvailable:     "tidak ada {mitra_lower} yang tersedia",
+	CopyMitraCancelledTitle:  "Order Dibatalkan {mitra}",
+	CopyMitraCancelledBody:   "{mitra} membatalkan order {order}",
+	CopyRedispatchSuffix:     ". Kami sedang mencarikan {mitra_lower} pengganti",
+	CopyBookingReminderBody:  "Kunjungan {mitra_lower} kamu dijadwalkan pukul {time}",
+	CopyBookingReminderMitra: "Kamu punya jadwal kunjungan pukul {time}",
+}
+
+// alur status default: 1 accepted → 2 OTW → 3 arrived → 4 selesai mitra.
+// Status 5 hanya lewat CancelServiceOrder dan 6 hanya dari 4 lewat
+// pembayaran customer (DeductCustomerBalance), tidak lewat update status.
+var defaultStatusFlow = map[int16][]int16{
+	1: {2},
+	2: {3},
+	3: {4},
+}
+
+var defaultRequiredDocuments = []string{"foto", "foto_ktp", "foto_selfie_ktp"}
+
+// Text copy notifikasi dengan placeholder terisi; vars: pasangan key, value
+func (v Vertical) Text(key string, vars ...string) string {
+	tmpl, ok := v.Copy[key]
+	if !ok || tmpl == "" {
+		tmpl = defaultVerticalCopy[key]
+	}
+
+	pairs := []string{
+		"{mitra_lower}", strings.ToLower(v.MitraLabel),
+		"{mitra}", v.MitraLabel,
+	}
+	for i := 0; i+1 < len(vars); i += 2 {
+		pairs = append(pairs, "{"+vars[i]+"}", vars[i+1])
+	}
+
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// CanTransition cek perubahan status order sesuai alur vertical
+func (v Vertical) CanTransition(from, to int16) bool {
+	for _, s := range v.StatusFlow[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// verticalRow baris job_categories mentah
+type verticalRow struct {
+	ID                int64
+	Name              string
+	OrderPrefix       *string
+	MitraLabel        *string
+	RequiredDocuments *string
+	StatusFlow        *string
+	NotificationCopy  *string
+}
+
+// GetVerticalRow ambil konfigurasi vertical dari job_categories
+func (r *Repository) GetVerticalRow(ctx context.Context, jobCategoryID int64) (verticalRow, error) {
+	var row verticalRow
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT id, name, order_prefix, mitra_label, required_documents,
+		       status_flow::text AS status_flow,
+		       notification_copy::text AS notification_copy
+		FROM job_categories
+		WHERE id = ?
+	`, jobCategoryID).Scan(&row).Error
+	return row, err
+}
+
+// GetOrderJobCategory job category dari service order
+func (r *Repository) GetOrderJobCategory(ctx context.Context, orderID int64) (int64, error) {
+	var catID int64
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT job_category_id FROM service_orders WHERE id = ?
+	`, orderID).Scan(&catID).Error
+	return catID, err
+}
+
+// buildVertical isi default untuk kolom yang kosong / tidak valid
+func buildVertical(jobCategoryID int64, row verticalRow) Vertical {
+	v := Vertical{
+		JobCategoryID:     jobCategoryID,
+		Name:              row.Name,
+		OrderPrefix:       "ORD",
+		MitraLabel:        "Mitra",
+		RequiredDocuments: defaultRequiredDocuments,
+		StatusFlow:        defaultStatusFlow,
+		Copy:              map[string]string{},
+	}
+
+	if row.OrderPrefix != nil && strings.TrimSpace(*row.OrderPrefix) != "" {
+		v.OrderPrefix = strings.ToUpper(strings.TrimSpace(*row.OrderPrefix))
+	}
+	if row.MitraLabel != nil && strings.TrimSpace(*row.MitraLabel) != "" {
+		v.MitraLabel = strings.TrimSpace(*row.MitraLabel)
+	}
+
+	if row.RequiredDocuments != nil && strings.TrimSpace(*row.RequiredDocuments) != "" {
+		var docs []string
+		for _, d := range strings.Split(*row.RequiredDocuments, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				docs = append(docs, d)
+			}
+		}
+		if len(docs) > 0 {
+			v.RequiredDocuments = docs
+		}
+	}
+
+	if row.StatusFlow != nil && *row.StatusFlow != "" {
+		var raw map[string][]int16
+		if err := json.Unmarshal([]byte(*row.StatusFlow), &raw); err != nil {
+			log.Printf("⚠️ vertical %d: invalid status_flow: %v", jobCategoryID, err)
+		} else {
+			flow := make(map[int16][]int16, len(raw))
+			for from, to := range raw {
+				n, err := strconv.ParseInt(from, 10, 16)
+				if err != nil {
+					continue
+				}
+				var next []int16
+				for _, s := range to {
+					// status 5 selalu lewat endpoint cancel, 6 lewat pembayaran
+					if s != 5 && s != 6 {
+						next = append(next, s)
+					}
+				}
+				flow[int16(n)] = next
+			}
+			if len(flow) > 0 {
+				v.StatusFlow = flow
+			}
+		}
+	}
+
+	if row.NotificationCopy != nil && *row.NotificationCopy != "" {
+		if err := json.Unmarshal([]byte(*row.NotificationCopy), &v.Copy); err != nil {
+			log.Printf("⚠️ vertical %d: invalid notification_copy: %v", jobCategoryID, err)
+			v.Copy = map[string]string{}
+		}
+	}
+
+	return v
+}
+
+const verticalCacheTTL = 5 * time.Minute
+
+type verticalCache struct {
+	mu    sync.RWMutex
+	items map[int64]verticalCacheItem
+}
+
+type verticalCacheItem struct {
+	v       Vertical
+	expires time.Time
+}
+
+func newVerticalCache() *verticalCache {
+	return &verticalCache{items: map[int64]verticalCacheItem{}}
+}
+
+// Vertical konfigurasi vertical job category (cache 5 menit). Jika gagal
+// dibaca dipakai default supaya flow order tidak terhenti.
+func (s *Service) Vertical(ctx context.Context, jobCategoryID int64) Vertical {
+	if s.verticals != nil {
+		s.verticals.mu.RLock()
+		item, ok := s.verticals.items[jobCategoryID]
+		s.verticals.mu.RUnlock()
+		if ok && time.Now().Before(item.expires) {
+			return item.v
+		}
+	}
+
+	row, err := s.Repo.GetVerticalRow(ctx, jobCategoryID)
+	if err != nil {
+		log.Printf("⚠️ vertical %d: %v", jobCategoryID, err)
+		return buildVertical(jobCategoryID, verticalRow{})
+	}
+
+	v := buildVertical(jobCategoryID, row)
+
+	if s.verticals != nil {
+		s.verticals.mu.Lock()
+		s.verticals.items[jobCategoryID] = verticalCacheItem{v: v, expires: time.Now().Add(verticalCacheTTL)}
+		s.verticals.mu.Unlock()
+	}
+
+	return v
+}
+
+// orderVertical konfigurasi vertical dari service order
+func (s *Service) orderVertical(ctx context.Context, orderID int64) Vertical {
+	catID, err := s.Repo.GetOrderJobCategory(ctx, orderID)
+	if err != nil {
+		log.Printf("⚠️ vertical order %d: %v", orderID, err)
+	}
+	return s.Vertical(ctx, catID)
+}
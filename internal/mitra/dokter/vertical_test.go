@@ -0,0 +1,233 @@
+package dokter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestBuildVerticalDefaults(t *testing.T) {
+	v := buildVertical(7, verticalRow{ID: 7, Name: "Perawat"})
+
+	if v.JobCategoryID != 7 || v.Name != "Perawat" {
+		t.Fatalf("id/name = %d/%q", v.JobCategoryID, v.Name)
+	}
+	if v.OrderPrefix != "ORD" {
+		t.Fatalf("OrderPrefix = %q, want ORD", v.OrderPrefix)
+	}
+	if v.MitraLabel != "Mitra" {
+		t.Fatalf("MitraLabel = %q, want Mitra", v.MitraLabel)
+	}
+	if !reflect.DeepEqual(v.RequiredDocuments, defaultRequiredDocuments) {
+		t.Fatalf("RequiredDocuments = %v", v.RequiredDocuments)
+	}
+	if !reflect.DeepEqual(v.StatusFlow, defaultStatusFlow) {
+		t.Fatalf("StatusFlow = %v", v.StatusFlow)
+	}
+}
+
+func TestBuildVerticalColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		row        verticalRow
+		wantPrefix string
+		wantLabel  string
+		wantDocs   []string
+	}{
+		{
+			name:       "prefix trimmed and upper cased",
+			row:        verticalRow{OrderPrefix: strPtr(" dok ")},
+			wantPrefix: "DOK",
+			wantLabel:  "Mitra",
+			wantDocs:   defaultRequiredDocuments,
+		},
+		{
+			name:       "blank prefix and label fall back",
+			row:        verticalRow{OrderPrefix: strPtr("  "), MitraLabel: strPtr(" ")},
+			wantPrefix: "ORD",
+			wantLabel:  "Mitra",
+			wantDocs:   defaultRequiredDocuments,
+		},
+		{
+			name:       "label trimmed",
+			row:        verticalRow{MitraLabel: strPtr(" Dokter ")},
+			wantPrefix: "ORD",
+			wantLabel:  "Dokter",
+			wantDocs:   defaultRequiredDocuments,
+		},
+		{
+			name:       "required documents split and trimmed",
+			row:        verticalRow{RequiredDocuments: strPtr("foto, foto_ktp,,str ,sip")},
+			wantPrefix: "ORD",
+			wantLabel:  "Mitra",
+			wantDocs:   []string{"foto", "foto_ktp", "str", "sip"},
+		},
+		{
+			name:       "only separators fall back",
+			row:        verticalRow{RequiredDocuments: strPtr(" , ,")},
+			wantPrefix: "ORD",
+			wantLabel:  "Mitra",
+			wantDocs:   defaultRequiredDocuments,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := buildVertical(1, tt.row)
+			if v.OrderPrefix != tt.wantPrefix {
+				t.Fatalf("OrderPrefix = %q, want %q", v.OrderPrefix, tt.wantPrefix)
+			}
+			if v.MitraLabel != tt.wantLabel {
+				t.Fatalf("MitraLabel = %q, want %q", v.MitraLabel, tt.wantLabel)
+			}
+			if !reflect.DeepEqual(v.RequiredDocuments, tt.wantDocs) {
+				t.Fatalf("RequiredDocuments = %v, want %v", v.RequiredDocuments, tt.wantDocs)
+			}
+		})
+	}
+}
+
+func TestVerticalStatusFlow(t *testing.T) {
+	type step struct {
+		from, to int16
+		want     bool
+	}
+
+	tests := []struct {
+		name  string
+		flow  *string
+		steps []step
+	}{
+		{
+			name: "default flow",
+			steps: []step{
+				{1, 2, true},
+				{2, 3, true},
+				{3, 4, true},
+				{1, 3, false},
+				{3, 2, false},
+				{4, 6, false}, // selesai hanya lewat pembayaran
+				{1, 5, false}, // batal hanya lewat CancelServiceOrder
+				{6, 4, false},
+			},
+		},
+		{
+			name: "custom flow skips OTW",
+			flow: strPtr(`{"1": [3], "3": [4]}`),
+			steps: []step{
+				{1, 3, true},
+				{3, 4, true},
+				{1, 2, false},
+				{2, 3, false},
+			},
+		},
+		{
+			name: "custom flow cannot reach cancelled or finished",
+			flow: strPtr(`{"1": [2, 5], "2": [3], "3": [4], "4": [6]}`),
+			steps: []step{
+				{1, 2, true},
+				{1, 5, false},
+				{3, 4, true},
+				{4, 6, false},
+			},
+		},
+		{
+			name: "invalid json keeps default",
+			flow: strPtr(`{"1": [2`),
+			steps: []step{
+				{1, 2, true},
+				{3, 4, true},
+				{4, 6, false},
+			},
+		},
+		{
+			name: "non numeric keys ignored",
+			flow: strPtr(`{"otw": [3], "1": [3]}`),
+			steps: []step{
+				{1, 3, true},
+				{1, 2, false},
+			},
+		},
+		{
+			name: "empty object keeps default",
+			flow: strPtr(`{}`),
+			steps: []step{
+				{1, 2, true},
+				{2, 3, true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := buildVertical(1, verticalRow{StatusFlow: tt.flow})
+			for _, s := range tt.steps {
+				if got := v.CanTransition(s.from, s.to); got != s.want {
+					t.Errorf("CanTransition(%d, %d) = %v, want %v", s.from, s.to, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerticalText(t *testing.T) {
+	v := buildVertical(1, verticalRow{
+		MitraLabel:       strPtr("Dokter"),
+		NotificationCopy: strPtr(`{"status_otw_title": "{mitra} berangkat", "not_found_title": ""}`),
+	})
+
+	tests := []struct {
+		name string
+		key  string
+		vars []string
+		want string
+	}{
+		{
+			name: "override",
+			key:  CopyStatusOTWTitle,
+			want: "Dokter berangkat",
+		},
+		{
+			name: "default with order placeholder",
+			key:  CopyStatusArrivedBody,
+			vars: []string{"order", "DOK-0001"},
+			want: "Dokter sudah sampai di lokasi untuk order DOK-0001",
+		},
+		{
+			name: "lower case label",
+			key:  CopyNoMitraAvailable,
+			want: "tidak ada dokter yang tersedia",
+		},
+		{
+			name: "empty override falls back to default",
+			key:  CopyNotFoundTitle,
+			want: "Dokter Tidak Ditemukan",
+		},
+		{
+			name: "odd vars ignored",
+			key:  CopyBookingReminderMitra,
+			vars: []string{"time", "09:30", "extra"},
+			want: "Kamu punya jadwal kunjungan pukul 09:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.Text(tt.key, tt.vars...); got != tt.want {
+				t.Fatalf("Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerticalInvalidCopy(t *testing.T) {
+	v := buildVertical(1, verticalRow{NotificationCopy: strPtr(`not json`)})
+
+	if len(v.Copy) != 0 {
+		t.Fatalf("Copy = %v, want empty", v.Copy)
+	}
+	if got, want := v.Text(CopyStatusOTWTitle), "Mitra OTW 🏎️"; got != want {
+		t.Fatalf("Text = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package dokter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"teka-api/internal/realtime/redis"
+)
+
+// channel Redis untuk event websocket antar replica
+const wsFanoutChannel = "dokter:ws_fanout"
+
+// wsEnvelope event hub yang diteruskan ke replica lain
+type wsEnvelope struct {
+	Origin string          `json:"origin"`
+	Hub    string          `json:"hub"`
+	Key    int64           `json:"key"`
+	Msg    json.RawMessage `json:"msg"`
+}
+
+// wsFanoutHub hub yang bisa menerima event dari replica lain
+type wsFanoutHub interface {
+	deliver(key int64, message interface{})
+	attachFanout(name string, f *WSFanout)
+}
+
+// WSFanout teruskan event hub lewat Redis pub/sub. Hub hanya menyimpan
+// koneksi di memori replica masing-masing, sedangkan worker (offer timeout,
+// perluasan radius, expiry) hanya jalan di leader; tanpa fan-out client yang
+// terhubung ke replica lain tidak pernah menerima event tersebut.
+type WSFanout struct {
+	origin string
+	hubs   map[string]wsFanoutHub
+}
+
+func NewWSFanout() *WSFanout {
+	host, _ := os.Hostname()
+	return &WSFanout{
+		origin: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		hubs:   make(map[string]wsFanoutHub),
+	}
+}
+
+// Register daftarkan hub dengan nama unik. Dipanggil sebelum server &
+// worker berjalan.
+func (f *WSFanout) Register(name string, hub wsFanoutHub) {
+	f.hubs[name] = hub
+	hub.attachFanout(name, f)
+}
+
+// publish kirim event ke replica lain; Redis mati → hanya lokal
+func (f *WSFanout) publish(hub string, key int64, message interface{}) {
+	if redis.Rdb == nil {
+		return
+	}
+
+	msg, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("❌ ws fanout marshal %s/%d: %v", hub, key, err)
+		return
+	}
+	payload, _ := json.Marshal(wsEnvelope{Origin: f.origin, Hub: hub, Key: key, Msg: msg})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := redis.Rdb.Publish(ctx, wsFanoutChannel, payload).Err(); err != nil {
+		log.Printf("⚠️ ws fanout publish %s/%d: %v", hub, key, err)
+	}
+}
+
+// Run subscribe event replica lain dan kirim ke koneksi lokal
+func (f *WSFanout) Run(ctx context.Context) {
+	if redis.Rdb == nil {
+		log.Println("⚠️ Redis disabled, websocket events stay on this replica")
+		return
+	}
+
+	sub := redis.Rdb.Subscribe(ctx, wsFanoutChannel)
+	defer sub.Close()
+
+	log.Println("📡 WS fanout subscribed")
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var env wsEnvelope
+			if err := json.Unmarshal([]byte(m.Payload), &env); err != nil {
+				log.Printf("⚠️ ws fanout invalid payload: %v", err)
+				continue
+			}
+			if env.Origin == f.origin {
+				continue // sudah dikirim lokal
+			}
+			if hub, ok := f.hubs[env.Hub]; ok {
+				hub.deliver(env.Key, env.Msg)
+			}
+		}
+	}
+}
+
+// hubFanout nama hub + fan-out, di-embed ke tiap hub
+type hubFanout struct {
+	name   string
+	fanout *WSFanout
+}
+
+func (h *hubFanout) attachFanout(name string, f *WSFanout) {
+	h.name = name
+	h.fanout = f
+}
+
+func (h *hubFanout) publish(key int64, message interface{}) {
+	if h.fanout != nil {
+		h.fanout.publish(h.name, key, message)
+	}
+}
@@ -1,8 +1,12 @@
 package dokter
 
 import (
+	"context"
+	"log"
 	"strconv"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
@@ -23,3 +27,134 @@ func (h *Handler) OrderStatusWS(c *websocket.Conn) {
 		}
 	}
 }
+
+// UserWS channel event pribadi user (JWT dari WebSocketJWTProtected)
+func (h *Handler) UserWS(c *websocket.Conn) {
+	userIDVal := c.Locals("user_id")
+	if userIDVal == nil {
+		_ = c.Close()
+		return
+	}
+	userID := int64(userIDVal.(uint))
+
+	h.Service.Users.Join(userID, c)
+	defer h.Service.Users.Leave(userID, c)
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+// RequestProgressWS progres pencarian dokter untuk satu customer request.
+// Hanya pemilik request yang boleh subscribe.
+func (h *Handler) RequestProgressWS(c *websocket.Conn) {
+	userIDVal := c.Locals("user_id")
+	if userIDVal == nil {
+		_ = c.Close()
+		return
+	}
+	customerID := int64(userIDVal.(uint))
+
+	requestID, err := strconv.ParseInt(c.Params("request_id"), 10, 64)
+	if err != nil {
+		_ = c.Close()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, err := h.Service.Repo.GetCustomerRequest(ctx, requestID)
+	cancel()
+
+	if err != nil || req.ID == 0 || req.CustomerID != customerID {
+		_ = c.WriteJSON(fiber.Map{"error": "request not found"})
+		_ = c.Close()
+		return
+	}
+
+	conn := h.Service.Requests.Join(requestID, c)
+	defer h.Service.Requests.Leave(requestID, c)
+
+	// snapshot lewat wrapper hub: broadcast worker bisa menulis bersamaan
+	_ = conn.WriteJSON(fiber.Map{
+		"event":         RequestEventSnapshot,
+		"request_id":    requestID,
+		"status_id":     req.StatusID,
+		"search_radius": req.SearchRadius,
+		"scheduled_at":  req.ScheduledAt,
+		"at":            time.Now(),
+	})
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+// MitraOfferWS offer realtime untuk mitra: snapshot offer aktif saat connect,
+// lalu offer baru / expired / withdrawn
+func (h *Handler) MitraOfferWS(c *websocket.Conn) {
+	userIDVal := c.Locals("user_id")
+	if userIDVal == nil {
+		_ = c.Close()
+		return
+	}
+	mitraID := int64(userIDVal.(uint))
+
+	conn := h.Service.Offers.Join(mitraID, c)
+	defer h.Service.Offers.Leave(mitraID, c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	offer, err := h.Service.Repo.GetCurrentOfferForMitra(ctx, mitraID)
+	cancel()
+	if err != nil {
+		offer = nil
+	}
+	_ = conn.WriteJSON(offerMessage(OfferEventSnapshot, offer))
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+// MitraLocationWS heartbeat lokasi mitra via websocket.
+// Client kirim {"latitude": .., "longitude": ..}, disimpan maksimal tiap 10 detik.
+func (h *Handler) MitraLocationWS(c *websocket.Conn) {
+	userIDVal := c.Locals("user_id")
+	if userIDVal == nil {
+		_ = c.Close()
+		return
+	}
+	mitraID := int64(userIDVal.(uint))
+
+	var lastSave time.Time
+
+	for {
+		var msg struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		}
+		if err := c.ReadJSON(&msg); err != nil {
+			break
+		}
+
+		if time.Since(lastSave) < 10*time.Second {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := h.Service.UpdateLocation(ctx, mitraID, msg.Latitude, msg.Longitude)
+		cancel()
+
+		if err != nil {
+			log.Printf("❌ location heartbeat mitra %d: %v", mitraID, err)
+			_ = c.WriteJSON(fiber.Map{"error": err.Error()})
+			continue
+		}
+		lastSave = time.Now()
+	}
+}
@@ -6,25 +6,48 @@ import (
 	"github.com/gofiber/websocket/v2"
 )
 
+// wsConn koneksi websocket dengan write lock. Library websocket tidak
+// mengizinkan writer bersamaan, sedangkan satu koneksi bisa ditulis worker,
+// handler HTTP dan snapshot saat connect secara paralel.
+type wsConn struct {
+	conn *websocket.Conn
+	wmu  sync.Mutex
+}
+
+func newWSConn(conn *websocket.Conn) *wsConn {
+	return &wsConn{conn: conn}
+}
+
+// WriteJSON kirim pesan JSON, satu writer per koneksi
+func (c *wsConn) WriteJSON(v interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
 type OrderHub struct {
+	hubFanout
 	mu    sync.RWMutex
-	rooms map[int]map[*websocket.Conn]bool
+	rooms map[int]map[*websocket.Conn]*wsConn
 }
 
 func NewOrderHub() *OrderHub {
 	return &OrderHub{
-		rooms: make(map[int]map[*websocket.Conn]bool),
+		rooms: make(map[int]map[*websocket.Conn]*wsConn),
 	}
 }
 
-func (h *OrderHub) Join(orderID int, conn *websocket.Conn) {
+// Join daftarkan koneksi, return wrapper yang dipakai untuk menulis ke koneksi
+func (h *OrderHub) Join(orderID int, conn *websocket.Conn) *wsConn {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if h.rooms[orderID] == nil {
-		h.rooms[orderID] = make(map[*websocket.Conn]bool)
+		h.rooms[orderID] = make(map[*websocket.Conn]*wsConn)
 	}
-	h.rooms[orderID][conn] = true
+	wc := newWSConn(conn)
+	h.rooms[orderID][conn] = wc
+	return wc
 }
 
 func (h *OrderHub) Leave(orderID int, conn *websocket.Conn) {
@@ -40,10 +63,124 @@ func (h *OrderHub) Leave(orderID int, conn *websocket.Conn) {
 }
 
 func (h *OrderHub) Broadcast(orderID int, message interface{}) {
+	h.deliver(int64(orderID), message)
+	h.publish(int64(orderID), message)
+}
+
+// deliver kirim ke koneksi di replica ini
+func (h *OrderHub) deliver(orderID int64, message interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, conn := range h.rooms[int(orderID)] {
+		_ = conn.WriteJSON(message)
+	}
+}
+
+// UserHub koneksi websocket per user (customer / mitra) untuk event
+// yang belum punya service order, misal request expired
+type UserHub struct {
+	hubFanout
+	mu    sync.RWMutex
+	conns map[int64]map[*websocket.Conn]*wsConn
+}
+
+func NewUserHub() *UserHub {
+	return &UserHub{
+		conns: make(map[int64]map[*websocket.Conn]*wsConn),
+	}
+}
+
+// Join daftarkan koneksi, return wrapper yang dipakai untuk menulis ke koneksi
+func (h *UserHub) Join(userID int64, conn *websocket.Conn) *wsConn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[userID] == nil {
+		h.conns[userID] = make(map[*websocket.Conn]*wsConn)
+	}
+	wc := newWSConn(conn)
+	h.conns[userID][conn] = wc
+	return wc
+}
+
+func (h *UserHub) Leave(userID int64, conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[userID] != nil {
+		delete(h.conns[userID], conn)
+		if len(h.conns[userID]) == 0 {
+			delete(h.conns, userID)
+		}
+	}
+}
+
+func (h *UserHub) Send(userID int64, message interface{}) {
+	h.deliver(userID, message)
+	h.publish(userID, message)
+}
+
+// deliver kirim ke koneksi di replica ini
+func (h *UserHub) deliver(userID int64, message interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, conn := range h.conns[userID] {
+		_ = conn.WriteJSON(message)
+	}
+}
+
+// RequestHub koneksi websocket customer per customer request (sebelum
+// ada service order): progres pencarian dokter
+type RequestHub struct {
+	hubFanout
+	mu    sync.RWMutex
+	rooms map[int64]map[*websocket.Conn]*wsConn
+}
+
+func NewRequestHub() *RequestHub {
+	return &RequestHub{
+		rooms: make(map[int64]map[*websocket.Conn]*wsConn),
+	}
+}
+
+// Join daftarkan koneksi, return wrapper yang dipakai untuk menulis ke koneksi
+func (h *RequestHub) Join(requestID int64, conn *websocket.Conn) *wsConn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[requestID] == nil {
+		h.rooms[requestID] = make(map[*websocket.Conn]*wsConn)
+	}
+	wc := newWSConn(conn)
+	h.rooms[requestID][conn] = wc
+	return wc
+}
+
+func (h *RequestHub) Leave(requestID int64, conn *websocket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[requestID] != nil {
+		delete(h.rooms[requestID], conn)
+		if len(h.rooms[requestID]) == 0 {
+			delete(h.rooms, requestID)
+		}
+	}
+}
+
+func (h *RequestHub) Broadcast(requestID int64, message interface{}) {
+	h.deliver(requestID, message)
+	h.publish(requestID, message)
+}
+
+// deliver kirim ke koneksi di replica ini
+func (h *RequestHub) deliver(requestID int64, message interface{}) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	for conn := range h.rooms[orderID] {
+	for _, conn := range h.rooms[requestID] {
 		_ = conn.WriteJSON(message)
 	}
 }
@@ -31,24 +31,85 @@ type CreateMitraRequest struct {
 	Longitude        float64 `form:"longitude"`
 }
 
+// MitraWorkingHour jam kerja mingguan mitra, day_of_week 0 = Minggu
+type MitraWorkingHour struct {
+	DayOfWeek int    `json:"day_of_week"`
+	StartTime string `json:"start_time"` // HH:MM
+	EndTime   string `json:"end_time"`   // HH:MM
+}
+
+// MitraDocument dokumen KYC mitra (mitra_documents)
 type MitraDocument struct {
-	UserID  int
-	DocType string
-	FileURL string
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	DocType       string     `json:"doc_type"`
+	FileKey       string     `json:"-" gorm:"column:file_url"` // object key MinIO (data lama: URL publik)
+	FileURL       string     `json:"file_url" gorm:"-"`        // presigned URL, diisi per request
+	ThumbKey      *string    `json:"-"`
+	ThumbURL      string     `json:"thumb_url,omitempty" gorm:"-"` // presigned URL thumbnail
+	ContentType   *string    `json:"content_type,omitempty"`
+	FileSize      *int64     `json:"file_size,omitempty"`
+	Width         *int       `json:"width,omitempty"`
+	Height        *int       `json:"height,omitempty"`
+	SHA256        *string    `json:"sha256,omitempty" gorm:"column:sha256"`
+	Status        string     `json:"status"` // PENDING | APPROVED | REJECTED
+	LicenseNumber *string    `json:"license_number,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // STR / SIP
+	ReviewComment *string    `json:"review_comment,omitempty"`
+	ReviewedBy    *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// MitraProfile profil mitra untuk GET /me
+type MitraProfile struct {
+	UserID             int64           `json:"user_id"`
+	Nama               string          `json:"nama"`
+	Email              string          `json:"email"`
+	Phone              string          `json:"phone"`
+	JobCategoryID      int64           `json:"job_category_id"`
+	JobCategoryName    string          `json:"job_category_name"`
+	JobSubCategoryID   *int64          `json:"job_sub_category_id,omitempty"`
+	JobSubCategoryName *string         `json:"job_sub_category_name,omitempty"`
+	MitraLabel         string          `json:"mitra_label"`
+	Bio                *string         `json:"bio"`
+	PhotoKey           *string         `json:"-"`
+	PhotoURL           *string         `json:"photo_url"` // presigned URL
+	PhotoThumbKey      *string         `json:"-"`
+	PhotoThumbURL      *string         `json:"photo_thumb_url"`
+	PracticeAddress    *string         `json:"practice_address"`
+	Specialities       []string        `json:"specialities"`
+	VerificationStatus string          `json:"verification_status"`
+	VerifiedAt         *time.Time      `json:"verified_at,omitempty"`
+	Online             bool            `json:"online"`
+	Rating             RatingSummary   `json:"rating"`
+	CompletedOrders    int64           `json:"completed_orders"`
+	Documents          []MitraDocument `json:"documents"`
+}
+
+// UpdateMitraProfileRequest field profil yang boleh diubah mitra (nil = tidak diubah)
+type UpdateMitraProfileRequest struct {
+	Bio             *string   `json:"bio"`
+	PracticeAddress *string   `json:"practice_address"`
+	Specialities    *[]string `json:"specialities"`
 }
 
 type DoctorSearchResult struct {
-	MitraID     int64   `json:"mitra_id"`
-	Nama        string  `json:"nama"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
-	AvgRating   float64 `json:"avg_rating"`
-	TotalReview int64   `json:"total_review"`
-	DistanceKm  float64 `json:"distance_km"`
-	IsBusy      bool    `json:"is_busy"` // tambahan untuk alert
+	MitraID             int64   `json:"mitra_id"`
+	Nama                string  `json:"nama"`
+	Latitude            float64 `json:"latitude"`
+	Longitude           float64 `json:"longitude"`
+	AvgRating           float64 `json:"avg_rating"`
+	TotalReview         int64   `json:"total_review"`
+	DistanceKm          float64 `json:"distance_km"`
+	AcceptanceRate      float64 `json:"acceptance_rate"`
+	RecentCancellations int64   `json:"recent_cancellations"`
+	Score               float64 `json:"score"`
+	IsBusy              bool    `json:"is_busy"` // tambahan untuk alert
 }
 
 type CustomerRequest struct {
+	ID               int64
 	CustomerID       int64
 	JobCategoryID    int64
 	JobSubCategoryID *int64
@@ -62,7 +123,11 @@ type CustomerRequest struct {
 	VoucherValue     *float64
 	PlatformFee      float64
 	THRBonus         float64
+	SearchRadius     float64
+	ScheduledAt      *time.Time
+	QuoteID          *string
 	Status           string
+	StatusID         int16
 }
 
 type CurrentOffer struct {
@@ -70,11 +135,13 @@ type CurrentOffer struct {
 	RequestID  int64 `json:"request_id"`
 	CustomerID int64 `json:"customer_id"`
 
-	Keluhan   string    `json:"keluhan"`
-	Latitude  float64   `json:"latitude"`
-	Longitude float64   `json:"longitude"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	Keluhan     string     `json:"keluhan"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	Price       float64    `json:"price"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	ExpiredAt   *time.Time `json:"expired_at,omitempty"`
 
 	CustomerName  string `json:"customer_name"`
 	CustomerPhone string `json:"customer_phone"`
@@ -161,10 +228,12 @@ type CustomerServiceOrder struct {
 }
 
 type ExpiredOffer struct {
-	ID        int64
-	RequestID int64
-	Sequence  int
-	MitraName string
+	ID            int64
+	RequestID     int64
+	Sequence      int
+	MitraID       int64
+	MitraName     string
+	JobCategoryID int64
 }
 
 type OfferAcceptData struct {
@@ -278,12 +347,32 @@ type CompleteOrderRequest struct {
 	Attachments []string `json:"attachments"`
 }
 
+// OrderAttachment lampiran / bukti layanan service order
+type OrderAttachment struct {
+	ID          int64     `json:"id"`
+	OrderID     int64     `json:"order_id"`
+	Type        string    `json:"type"`                // photo | document | signature
+	FileKey     string    `json:"-" gorm:"column:url"` // object key MinIO
+	FileURL     string    `json:"file_url" gorm:"-"`   // presigned URL, diisi per request
+	ThumbKey    *string   `json:"-"`
+	ThumbURL    string    `json:"thumb_url,omitempty" gorm:"-"` // presigned URL thumbnail
+	ContentType *string   `json:"content_type,omitempty"`
+	FileSize    *int64    `json:"file_size,omitempty"`
+	Width       *int      `json:"width,omitempty"`
+	Height      *int      `json:"height,omitempty"`
+	UploadedBy  *int64    `json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 type ChatMessage struct {
 	OrderID    int64     `json:"order_id"`
 	SenderID   int64     `json:"sender_id"`
 	SenderType string    `json:"sender_type"` // "mitra" or "customer"
 	Message    string    `json:"message"`
 	CreatedAt  time.Time `json:"created_at"`
+	// lampiran (upload session), URL diambil lewat /api/files/order-attachments/:id
+	AttachmentID          *int64 `json:"attachment_id,omitempty"`
+	AttachmentContentType string `json:"attachment_content_type,omitempty"`
 }
 
 type FCMLog struct {
@@ -301,3 +390,23 @@ type LocationUpdate struct {
 	Lng     float64 `json:"lng"`
 	Sender  string  `json:"sender"` // mitra
 }
+
+// MitraDocumentReview item antrian review dokumen (admin)
+type MitraDocumentReview struct {
+	MitraDocument
+	MitraName          string `json:"mitra_name"`
+	MitraPhone         string `json:"mitra_phone"`
+	JobCategoryID      int64  `json:"job_category_id"`
+	VerificationStatus string `json:"verification_status"`
+}
+
+// MitraVerification status verifikasi mitra + dokumen aktif
+type MitraVerification struct {
+	UserID             int64           `json:"user_id"`
+	JobCategoryID      int64           `json:"job_category_id"`
+	VerificationStatus string          `json:"verification_status"`
+	VerifiedAt         *time.Time      `json:"verified_at,omitempty"`
+	RequiredDocuments  []string        `json:"required_documents"`
+	MissingDocuments   []string        `json:"missing_documents"`
+	Documents          []MitraDocument `json:"documents"`
+}
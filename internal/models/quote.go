@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CustomerQuote rincian harga yang dihitung server untuk satu customer
+type CustomerQuote struct {
+	ID               string     `json:"-"`
+	CustomerID       int64      `json:"-"`
+	JobCategoryID    int64      `json:"job_category_id"`
+	JobSubCategoryID *int64     `json:"job_sub_category_id,omitempty"`
+	JobTariffID      int64      `json:"job_tariff_id"`
+	Price            float64    `json:"price"`
+	PlatformFee      float64    `json:"platform_fee"`
+	THRBonus         float64    `json:"thr_bonus"`
+	VoucherID        *int64     `json:"voucher_id,omitempty"`
+	VoucherValue     float64    `json:"voucher_value"`
+	Total            float64    `json:"total"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	UsedAt           *time.Time `json:"-"`
+	CreatedAt        time.Time  `json:"-"`
+}
+
+func (CustomerQuote) TableName() string {
+	return "customer_quotes"
+}
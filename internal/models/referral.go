@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Referral relasi pengajak (referrer) dan user baru (referee)
+type Referral struct {
+	ID             int64      `gorm:"primaryKey" json:"id"`
+	ReferrerID     int64      `json:"referrer_id"`
+	RefereeID      int64      `json:"referee_id"`
+	ReferralCode   string     `json:"referral_code"`
+	DeviceID       *string    `json:"-"`
+	RefereePhone   string     `json:"-"`
+	Status         string     `json:"status"` // PENDING | REWARDED | REJECTED
+	RejectReason   *string    `json:"reject_reason,omitempty"`
+	OrderID        *int64     `json:"order_id,omitempty"`
+	ReferrerReward int64      `json:"referrer_reward"`
+	RefereeReward  int64      `json:"referee_reward"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RewardedAt     *time.Time `json:"rewarded_at,omitempty"`
+}
+
+func (Referral) TableName() string {
+	return "referrals"
+}
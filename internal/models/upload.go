@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// UploadSession sesi upload langsung ke storage (presigned POST policy)
+type UploadSession struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	Purpose        string     `json:"purpose"` // MITRA_DOCUMENT | ORDER_ATTACHMENT | CHAT_MESSAGE
+	DocType        *string    `json:"doc_type,omitempty"`
+	AttachmentType *string    `json:"attachment_type,omitempty"`
+	OrderID        *int64     `json:"order_id,omitempty"`
+	Bucket         string     `json:"-"`
+	ObjectKey      string     `json:"-"`
+	ContentType    string     `json:"content_type"`
+	DeclaredSize   int64      `json:"declared_size"`
+	ActualSize     *int64     `json:"actual_size,omitempty"`
+	Status         string     `json:"status"` // PENDING | FINALIZED | FAILED
+	FailReason     *string    `json:"fail_reason,omitempty"`
+	AttachmentID   *int64     `json:"attachment_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	FinalizedAt    *time.Time `json:"finalized_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CreateUploadSessionRequest body POST /api/uploads
+type CreateUploadSessionRequest struct {
+	Purpose        string `json:"purpose"`
+	ContentType    string `json:"content_type"`
+	Size           int64  `json:"size"`
+	DocType        string `json:"doc_type"`        // MITRA_DOCUMENT
+	OrderID        int64  `json:"order_id"`        // ORDER_ATTACHMENT / CHAT_MESSAGE
+	AttachmentType string `json:"attachment_type"` // ORDER_ATTACHMENT: photo | document | signature
+}
+
+// FinalizeUploadRequest body POST /api/uploads/:id/finalize
+type FinalizeUploadRequest struct {
+	LicenseNumber string `json:"license_number"` // dokumen lisensi (STR / SIP)
+	ExpiresAt     string `json:"expires_at"`     // YYYY-MM-DD
+	Message       string `json:"message"`        // caption pesan chat
+}
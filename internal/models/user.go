@@ -15,6 +15,9 @@ type User struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
+	ReferralCode     *string // kode referral milik user
+	RegisterDeviceID *string // device saat registrasi (anti-abuse referral)
+
 	// Relasi ke pivot, termasuk info Active
 	UserRoles []UserRole `gorm:"foreignKey:UserID"`
 }
@@ -35,6 +38,9 @@ type TempUser struct {
 	Email     string
 	Password  string
 	CreatedAt time.Time
+
+	ReferralCode *string // kode referral pengajak (opsional)
+	DeviceID     *string
 }
 
 type OTP struct {
@@ -14,8 +14,19 @@ type Voucher struct {
 	IsActive      bool      `json:"is_active"`
 	MaxUsage      *int      `json:"max_usage"`
 	UsedCount     int       `json:"used_count"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ReservedCount int       `json:"reserved_count"`
+
+	// Targeting (NULL = tidak dibatasi)
+	JobCategoryID    *int64   `json:"job_category_id"`
+	JobSubCategoryID *int64   `json:"job_sub_category_id"`
+	MinOrderValue    *float64 `json:"min_order_value"`
+	MaxDiscount      *float64 `json:"max_discount"` // batas potongan voucher PERCENT
+	PerUserLimit     int      `json:"per_user_limit"`
+	NewCustomerOnly  bool     `json:"new_customer_only"`
+	BatchCode        *string  `json:"batch_code"` // diisi untuk kode sekali pakai hasil bulk generate
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (Voucher) TableName() string {
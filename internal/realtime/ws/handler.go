@@ -262,30 +262,40 @@ func ChatWebSocketHandler(c *websocket.Conn) {
 		log.Printf("📨 Received message from user=%d (%s) in order=%s: \"%s\"",
 			userID, senderType, orderIDStr, req.Message)
 
-		// 3️⃣ Save to Redis (if available) with timeout
-		if redis.Rdb != nil {
-			msgJSON, err := json.Marshal(msg)
-			if err != nil {
-				log.Printf("⚠️ Error marshaling message: %v", err)
-			} else {
-				// Save to Redis with timeout in background to not block message broadcasting
-				go func() {
-					saveCtx, saveCancel := context.WithTimeout(context.Background(), 2*time.Second)
-					defer saveCancel()
+		// 3️⃣ Save to Redis + 4️⃣ Broadcast to all in room
+		PostChatMessage(msg)
+	}
+}
 
-					if err := redis.Rdb.RPush(saveCtx, cacheKey, msgJSON).Err(); err != nil {
-						log.Printf("⚠️ Error saving message to Redis: %v", err)
-					}
-					if err := redis.Rdb.Expire(saveCtx, cacheKey, 24*time.Hour).Err(); err != nil {
-						log.Printf("⚠️ Error setting Redis expiry: %v", err)
-					}
-				}()
-			}
-		}
+// PostChatMessage simpan pesan ke history Redis (jika ada) lalu broadcast
+// ke semua client chat order. Dipakai juga untuk pesan berlampiran dari
+// upload session.
+func PostChatMessage(msg models.ChatMessage) {
+	orderIDStr := strconv.FormatInt(msg.OrderID, 10)
+	cacheKey := fmt.Sprintf("order_chat:%s", orderIDStr)
 
-		// 4️⃣ Broadcast to all in room
-		BroadcastChat(orderIDStr, msg)
+	// Save to Redis (if available) with timeout
+	if redis.Rdb != nil {
+		msgJSON, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("⚠️ Error marshaling message: %v", err)
+		} else {
+			// Save to Redis with timeout in background to not block message broadcasting
+			go func() {
+				saveCtx, saveCancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer saveCancel()
+
+				if err := redis.Rdb.RPush(saveCtx, cacheKey, msgJSON).Err(); err != nil {
+					log.Printf("⚠️ Error saving message to Redis: %v", err)
+				}
+				if err := redis.Rdb.Expire(saveCtx, cacheKey, 24*time.Hour).Err(); err != nil {
+					log.Printf("⚠️ Error setting Redis expiry: %v", err)
+				}
+			}()
+		}
 	}
+
+	BroadcastChat(orderIDStr, msg)
 }
 
 func BroadcastChat(orderID string, msg models.ChatMessage) {
@@ -0,0 +1,41 @@
+package referral
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GET /api/user/referral
+func (h *Handler) GetMyReferral(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	sum, err := h.service.GetSummary(int64(userID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"message": "failed get referral",
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": sum})
+}
+
+// GET /api/user/referral/list
+func (h *Handler) GetMyReferrals(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	refs, err := h.service.GetReferrals(int64(userID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"message": "failed get referrals",
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": refs})
+}
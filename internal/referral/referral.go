@@ -0,0 +1,344 @@
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// Status referral (referrals.status)
+const (
+	StatusPending  = "PENDING"
+	StatusRewarded = "REWARDED"
+	StatusRejected = "REJECTED"
+)
+
+// Alasan referral ditolak (referrals.reject_reason)
+const (
+	RejectSelfReferral  = "SELF_REFERRAL"
+	RejectSamePhone     = "SAME_PHONE"
+	RejectPhoneReused   = "PHONE_REUSED"
+	RejectDeviceMissing = "DEVICE_MISSING"
+	RejectDeviceReused  = "DEVICE_REUSED"
+	RejectNotFirstOrder = "NOT_FIRST_ORDER"
+	RejectQuotaReached  = "QUOTA_REACHED"
+)
+
+const (
+	codeLength   = 8
+	codeAttempts = 5
+
+	saldoCategoryReward = 7 // REFERRAL_REWARD
+)
+
+var ErrInvalidCode = errors.New("kode referral tidak valid")
+
+// NormalizeCode kode referral huruf besar tanpa spasi
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NormalizePhone samakan format nomor (+62 / 62 / 0) → 08xxx
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	digits := b.String()
+	switch {
+	case strings.HasPrefix(digits, "62"):
+		digits = "0" + digits[2:]
+	case strings.HasPrefix(digits, "8"):
+		digits = "0" + digits
+	}
+	return digits
+}
+
+// FindReferrerID user pemilik kode referral
+func FindReferrerID(db *gorm.DB, code string) (int64, error) {
+	var id int64
+	if err := db.Raw(`
+		SELECT id FROM users WHERE referral_code = ?
+	`, NormalizeCode(code)).Scan(&id).Error; err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, ErrInvalidCode
+	}
+	return id, nil
+}
+
+// AssignCode buat kode referral unik untuk user yang belum punya
+func AssignCode(db *gorm.DB, userID int64) (string, error) {
+	for i := 0; i < codeAttempts; i++ {
+		code, err := utils.RandomCode("", codeLength)
+		if err != nil {
+			return "", err
+		}
+
+		var assigned string
+		res := db.Raw(`
+			UPDATE users
+			SET referral_code = COALESCE(referral_code, ?)
+			WHERE id = ?
+			  AND NOT EXISTS (SELECT 1 FROM users WHERE referral_code = ?)
+			RETURNING referral_code
+		`, code, userID, code).Scan(&assigned)
+		if res.Error != nil {
+			return "", res.Error
+		}
+		if res.RowsAffected > 0 {
+			return assigned, nil
+		}
+	}
+	return "", errors.New("gagal membuat kode referral")
+}
+
+// CaptureInput data registrasi user baru yang memakai kode referral
+type CaptureInput struct {
+	RefereeID int64
+	Code      string
+	Phone     string
+	DeviceID  string
+}
+
+// Capture catat referral saat registrasi selesai (dalam transaksi
+// VerifyAndRegister). Referral yang gagal cek anti-abuse tetap dicatat
+// sebagai REJECTED supaya tidak bisa diklaim ulang; registrasi tidak gagal.
+func Capture(tx *gorm.DB, in CaptureInput) error {
+	referrerID, err := FindReferrerID(tx, in.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Printf("⚠️ referral: unknown code %q for user %d", in.Code, in.RefereeID)
+			return nil
+		}
+		return err
+	}
+
+	phone := NormalizePhone(in.Phone)
+
+	ref := models.Referral{
+		ReferrerID:   referrerID,
+		RefereeID:    in.RefereeID,
+		ReferralCode: NormalizeCode(in.Code),
+		RefereePhone: phone,
+		Status:       StatusPending,
+		CreatedAt:    time.Now(),
+	}
+	if in.DeviceID != "" {
+		device := in.DeviceID
+		ref.DeviceID = &device
+	}
+
+	reason, err := abuseCheck(tx, referrerID, in.RefereeID, phone, in.DeviceID)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		ref.Status = StatusRejected
+		ref.RejectReason = &reason
+		log.Printf("🚫 referral %s → user %d rejected: %s", ref.ReferralCode, in.RefereeID, reason)
+	}
+
+	return tx.Create(&ref).Error
+}
+
+// abuseCheck return alasan penolakan, kosong jika lolos
+func abuseCheck(tx *gorm.DB, referrerID, refereeID int64, phone, deviceID string) (string, error) {
+	if referrerID == refereeID {
+		return RejectSelfReferral, nil
+	}
+
+	var referrerPhone string
+	if err := tx.Raw(`SELECT phone FROM users WHERE id = ?`, referrerID).Scan(&referrerPhone).Error; err != nil {
+		return "", err
+	}
+	if NormalizePhone(referrerPhone) == phone {
+		return RejectSamePhone, nil
+	}
+
+	// nomor yang sama pernah jadi referee (akun lama dihapus / daftar ulang)
+	var phoneUsed bool
+	if err := tx.Raw(`
+		SELECT EXISTS (SELECT 1 FROM referrals WHERE referee_phone = ? AND referee_id <> ?)
+	`, phone, refereeID).Scan(&phoneUsed).Error; err != nil {
+		return "", err
+	}
+	if phoneUsed {
+		return RejectPhoneReused, nil
+	}
+
+	if deviceID == "" {
+		return RejectDeviceMissing, nil
+	}
+
+	// device pernah dipakai registrasi / login akun lain, termasuk pengajak
+	var deviceUsed bool
+	if err := tx.Raw(`
+		SELECT EXISTS (SELECT 1 FROM users WHERE register_device_id = ? AND id <> ?)
+		    OR EXISTS (SELECT 1 FROM user_fcm_tokens WHERE device_id = ? AND user_id <> ?)
+		    OR EXISTS (SELECT 1 FROM referrals WHERE device_id = ? AND referee_id <> ?)
+	`, deviceID, refereeID, deviceID, refereeID, deviceID, refereeID).Scan(&deviceUsed).Error; err != nil {
+		return "", err
+	}
+	if deviceUsed {
+		return RejectDeviceReused, nil
+	}
+
+	return "", nil
+}
+
+// RewardResult reward yang dikreditkan saat order pertama referee selesai
+type RewardResult struct {
+	ReferrerID     int64
+	ReferrerReward int64
+	RefereeReward  int64
+}
+
+// RewardFirstOrder kredit saldo pengajak & referee saat order pertama referee
+// selesai (status 6). Dipanggil di dalam transaksi penyelesaian order; return
+// nil jika tidak ada referral PENDING.
+func RewardFirstOrder(tx *gorm.DB, refereeID, orderID int64, orderNumber string) (*RewardResult, error) {
+	var ref models.Referral
+	if err := tx.Raw(`
+		SELECT * FROM referrals
+		WHERE referee_id = ? AND status = ?
+		FOR UPDATE
+	`, refereeID, StatusPending).Scan(&ref).Error; err != nil {
+		return nil, err
+	}
+	if ref.ID == 0 {
+		return nil, nil
+	}
+
+	// hanya order selesai pertama
+	var finished int64
+	if err := tx.Raw(`
+		SELECT COUNT(*) FROM service_orders WHERE customer_id = ? AND status_id = 6
+	`, refereeID).Scan(&finished).Error; err != nil {
+		return nil, err
+	}
+	if finished > 1 {
+		return nil, reject(tx, ref.ID, RejectNotFirstOrder)
+	}
+
+	// cek ulang device: referee bisa saja login di device pengajak setelah daftar
+	if ref.DeviceID != nil {
+		var shared bool
+		if err := tx.Raw(`
+			SELECT EXISTS (
+				SELECT 1 FROM user_fcm_tokens WHERE device_id = ? AND user_id = ?
+			)
+		`, *ref.DeviceID, ref.ReferrerID).Scan(&shared).Error; err != nil {
+			return nil, err
+		}
+		if shared {
+			return nil, reject(tx, ref.ID, RejectDeviceReused)
+		}
+	}
+
+	if max := intParam(tx, "REFERRAL_MAX_PER_REFERRER", 0); max > 0 {
+		var rewarded int64
+		if err := tx.Raw(`
+			SELECT COUNT(*) FROM referrals WHERE referrer_id = ? AND status = ?
+		`, ref.ReferrerID, StatusRewarded).Scan(&rewarded).Error; err != nil {
+			return nil, err
+		}
+		if rewarded >= int64(max) {
+			return nil, reject(tx, ref.ID, RejectQuotaReached)
+		}
+	}
+
+	res := &RewardResult{
+		ReferrerID:     ref.ReferrerID,
+		ReferrerReward: int64(intParam(tx, "REFERRAL_REWARD_REFERRER", 0)),
+		RefereeReward:  int64(intParam(tx, "REFERRAL_REWARD_REFEREE", 0)),
+	}
+
+	reference := fmt.Sprintf("REF-%d", ref.ID)
+	if err := creditTx(tx, ref.ReferrerID, reference, res.ReferrerReward,
+		"Reward referral: teman menyelesaikan order pertama"); err != nil {
+		return nil, err
+	}
+	if err := creditTx(tx, refereeID, reference, res.RefereeReward,
+		"Reward referral: order pertama "+orderNumber); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Exec(`
+		UPDATE referrals
+		SET status = ?, order_id = ?, referrer_reward = ?, referee_reward = ?, rewarded_at = NOW()
+		WHERE id = ?
+	`, StatusRewarded, orderID, res.ReferrerReward, res.RefereeReward, ref.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func reject(tx *gorm.DB, referralID int64, reason string) error {
+	log.Printf("🚫 referral %d rejected at reward: %s", referralID, reason)
+	return tx.Exec(`
+		UPDATE referrals SET status = ?, reject_reason = ? WHERE id = ?
+	`, StatusRejected, reason, referralID).Error
+}
+
+// creditTx mutasi IN saldo reward (kunci baris saldo terakhir user)
+func creditTx(tx *gorm.DB, userID int64, reference string, amount int64, description string) error {
+	if amount <= 0 {
+		return nil
+	}
+
+	var latestSaldo int64
+	if err := tx.Raw(`
+		SELECT saldo_setelah
+		FROM myschema.saldo_role_transactions
+		WHERE user_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+		FOR UPDATE
+	`, userID).Scan(&latestSaldo).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return tx.Create(&models.SaldoTransaction{
+		UserID:        uint(userID),
+		ReferenceID:   reference,
+		ReferenceType: "REFERRAL",
+		MutationType:  "IN",
+		CategoryID:    saldoCategoryReward,
+		Amount:        amount,
+		SaldoSetelah:  latestSaldo + amount,
+		Description:   description,
+		CreatedAt:     time.Now(),
+	}).Error
+}
+
+// intParam global parameter angka, def jika kosong / tidak valid
+func intParam(db *gorm.DB, code string, def int) int {
+	var val string
+	if err := db.Raw(`
+		SELECT parameter_value FROM global_parameter
+		WHERE parameter_code = ? AND is_active = 'true'
+		LIMIT 1
+	`, code).Scan(&val).Error; err != nil || val == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
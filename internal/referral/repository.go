@@ -0,0 +1,56 @@
+package referral
+
+import (
+	"teka-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetOrAssignCode(userID int64) (string, error)
+	GetSummary(userID int64) (Summary, error)
+	GetReferrals(userID int64) ([]models.Referral, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetOrAssignCode(userID int64) (string, error) {
+	var code *string
+	if err := r.db.Raw(`SELECT referral_code FROM users WHERE id = ?`, userID).Scan(&code).Error; err != nil {
+		return "", err
+	}
+	if code != nil && *code != "" {
+		return *code, nil
+	}
+
+	// user lama sebelum program referral
+	return AssignCode(r.db, userID)
+}
+
+func (r *repository) GetSummary(userID int64) (Summary, error) {
+	var s Summary
+	err := r.db.Raw(`
+		SELECT
+			COUNT(*) FILTER (WHERE status = ?) AS pending,
+			COUNT(*) FILTER (WHERE status = ?) AS rewarded,
+			COALESCE(SUM(referrer_reward) FILTER (WHERE status = ?), 0) AS total_reward
+		FROM referrals
+		WHERE referrer_id = ?
+	`, StatusPending, StatusRewarded, StatusRewarded, userID).Scan(&s).Error
+	return s, err
+}
+
+func (r *repository) GetReferrals(userID int64) ([]models.Referral, error) {
+	var refs []models.Referral
+	err := r.db.
+		Where("referrer_id = ?", userID).
+		Order("created_at DESC").
+		Find(&refs).Error
+	return refs, err
+}
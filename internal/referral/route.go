@@ -0,0 +1,20 @@
+package referral
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"teka-api/pkg/middleware"
+)
+
+func Routes(app *fiber.App, db *gorm.DB) {
+	repo := NewRepository(db)
+	service := NewService(repo)
+	handler := NewHandler(service)
+
+	api := app.Group("/api")
+
+	user := api.Group("/user/referral", middleware.JWTProtected())
+	user.Get("", handler.GetMyReferral)
+	user.Get("/list", handler.GetMyReferrals)
+}
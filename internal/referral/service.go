@@ -0,0 +1,43 @@
+package referral
+
+import "teka-api/internal/models"
+
+// Summary ringkasan referral milik user
+type Summary struct {
+	Code        string `json:"code"`
+	Pending     int64  `json:"pending"`
+	Rewarded    int64  `json:"rewarded"`
+	TotalReward int64  `json:"total_reward"`
+}
+
+type Service interface {
+	GetSummary(userID int64) (Summary, error)
+	GetReferrals(userID int64) ([]models.Referral, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) GetSummary(userID int64) (Summary, error) {
+	code, err := s.repo.GetOrAssignCode(userID)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	sum, err := s.repo.GetSummary(userID)
+	if err != nil {
+		return Summary{}, err
+	}
+	sum.Code = code
+
+	return sum, nil
+}
+
+func (s *service) GetReferrals(userID int64) ([]models.Referral, error) {
+	return s.repo.GetReferrals(userID)
+}
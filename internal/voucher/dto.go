@@ -0,0 +1,33 @@
+package voucher
+
+// VoucherInput body create / update voucher (admin)
+type VoucherInput struct {
+	Code             string   `json:"code"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	DiscountType     string   `json:"discount_type"` // PERCENT | FIXED
+	DiscountValue    float64  `json:"discount_value"`
+	StartDate        string   `json:"start_date"` // YYYY-MM-DD
+	EndDate          string   `json:"end_date"`   // YYYY-MM-DD
+	IsActive         *bool    `json:"is_active"`
+	MaxUsage         *int     `json:"max_usage"`
+	JobCategoryID    *int64   `json:"job_category_id"`
+	JobSubCategoryID *int64   `json:"job_sub_category_id"`
+	MinOrderValue    *float64 `json:"min_order_value"`
+	MaxDiscount      *float64 `json:"max_discount"`
+	PerUserLimit     int      `json:"per_user_limit"`
+	NewCustomerOnly  bool     `json:"new_customer_only"`
+}
+
+// BulkGenerateInput generate kode unik sekali pakai dari satu template
+type BulkGenerateInput struct {
+	VoucherInput
+	Prefix   string `json:"prefix"`
+	Quantity int    `json:"quantity"`
+}
+
+// BulkGenerateResult hasil bulk generate
+type BulkGenerateResult struct {
+	BatchCode string   `json:"batch_code"`
+	Codes     []string `json:"codes"`
+}
@@ -1,7 +1,11 @@
 package voucher
 
 import (
+	"errors"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
@@ -24,6 +28,110 @@ func (h *Handler) GetAdminVouchers(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"data": vouchers})
 }
 
+// GET /admin/vouchers/all?batch_code=
+func (h *Handler) GetAllVouchers(c *fiber.Ctx) error {
+	vouchers, err := h.service.GetAll(c.Query("batch_code"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"message": "failed get vouchers",
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": vouchers})
+}
+
+// GET /admin/vouchers/:id
+func (h *Handler) GetVoucher(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid id"})
+	}
+
+	v, err := h.service.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"message": "voucher not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": v})
+}
+
+// POST /admin/vouchers
+func (h *Handler) CreateVoucher(c *fiber.Ctx) error {
+	var in VoucherInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid body"})
+	}
+
+	v, err := h.service.Create(in)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": v})
+}
+
+// PUT /admin/vouchers/:id
+func (h *Handler) UpdateVoucher(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid id"})
+	}
+
+	var in VoucherInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid body"})
+	}
+
+	v, err := h.service.Update(uint(id), in)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"message": "voucher not found"})
+		}
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": v})
+}
+
+// DELETE /admin/vouchers/:id
+func (h *Handler) DeleteVoucher(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid id"})
+	}
+
+	deactivated, err := h.service.Delete(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{"message": "voucher not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	if deactivated {
+		return c.JSON(fiber.Map{"message": "voucher sudah pernah dipakai, dinonaktifkan"})
+	}
+	return c.JSON(fiber.Map{"message": "deleted"})
+}
+
+// POST /admin/vouchers/bulk
+func (h *Handler) BulkGenerateVouchers(c *fiber.Ctx) error {
+	var in BulkGenerateInput
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": "invalid body"})
+	}
+
+	res, err := h.service.BulkGenerate(in)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{"data": res})
+}
+
 // USER
 func (h *Handler) GetUserVouchers(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
@@ -0,0 +1,285 @@
+package voucher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"teka-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Tipe potongan voucher (vouchers.discount_type)
+const (
+	DiscountPercent = "PERCENT"
+	DiscountFixed   = "FIXED"
+)
+
+// Status reservasi voucher (voucher_reservations.status)
+const (
+	ReservationReserved = "RESERVED" // dipegang customer request yang masih berjalan
+	ReservationRedeemed = "REDEEMED" // order selesai dibayar, masuk voucher_histories
+	ReservationReleased = "RELEASED" // request batal / expired, kuota dikembalikan
+)
+
+// Error eligibility voucher (pesan dikirim apa adanya ke client)
+var (
+	ErrNotEligible       = errors.New("voucher tidak berlaku")
+	ErrQuotaExhausted    = errors.New("kuota voucher sudah habis")
+	ErrUsageLimitReached = errors.New("batas pemakaian voucher kamu sudah tercapai")
+	ErrWrongService      = errors.New("voucher tidak berlaku untuk layanan ini")
+	ErrNewCustomerOnly   = errors.New("voucher hanya untuk pelanggan baru")
+	ErrMinOrder          = errors.New("nilai order belum memenuhi minimum voucher")
+)
+
+// EligibilityInput konteks pemakaian voucher
+type EligibilityInput struct {
+	VoucherID        int64
+	Code             string // alternatif VoucherID, mis. kode sekali pakai
+	UserID           int64
+	JobCategoryID    int64
+	JobSubCategoryID int64
+	Amount           float64 // harga layanan yang dipotong voucher
+}
+
+// voucherRow voucher + status periode dihitung di DB (CURRENT_DATE)
+type voucherRow struct {
+	models.Voucher
+	InPeriod bool
+}
+
+// CheckEligibility validasi voucher untuk user dan hitung potongannya.
+func CheckEligibility(db *gorm.DB, in EligibilityInput) (*models.Voucher, float64, error) {
+	v, err := loadVoucher(db, in, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := validate(db, v, in); err != nil {
+		return nil, 0, err
+	}
+
+	return &v.Voucher, Discount(&v.Voucher, in.Amount), nil
+}
+
+// loadVoucher ambil voucher berdasarkan id / kode; lock = FOR UPDATE
+func loadVoucher(db *gorm.DB, in EligibilityInput, lock bool) (*voucherRow, error) {
+	query := `
+		SELECT v.*,
+		       CURRENT_DATE BETWEEN v.start_date AND v.end_date AS in_period
+		FROM vouchers v
+	`
+	var args []interface{}
+	if in.VoucherID != 0 {
+		query += ` WHERE v.id = ?`
+		args = append(args, in.VoucherID)
+	} else {
+		query += ` WHERE UPPER(v.code) = UPPER(?)`
+		args = append(args, strings.TrimSpace(in.Code))
+	}
+	if lock {
+		query += ` FOR UPDATE OF v`
+	}
+
+	var v voucherRow
+	if err := db.Raw(query, args...).Scan(&v).Error; err != nil {
+		return nil, err
+	}
+	if v.ID == 0 {
+		return nil, ErrNotEligible
+	}
+	return &v, nil
+}
+
+// validate cek seluruh aturan voucher: periode, kuota, target layanan,
+// minimum order, batas pemakaian per user, pelanggan baru
+func validate(db *gorm.DB, v *voucherRow, in EligibilityInput) error {
+	if err := checkRules(v, in); err != nil {
+		return err
+	}
+
+	var used int64
+	if err := db.Raw(`
+		SELECT
+			(SELECT COUNT(*) FROM voucher_histories WHERE voucher_id = ? AND user_id = ?) +
+			(SELECT COUNT(*) FROM voucher_reservations WHERE voucher_id = ? AND user_id = ? AND status = ?)
+	`, v.ID, in.UserID, v.ID, in.UserID, ReservationReserved).Scan(&used).Error; err != nil {
+		return err
+	}
+	if err := checkUserLimit(&v.Voucher, used); err != nil {
+		return err
+	}
+
+	if v.NewCustomerOnly {
+		var finished bool
+		if err := db.Raw(`
+			SELECT EXISTS (
+				SELECT 1 FROM service_orders
+				WHERE customer_id = ? AND status_id = 6
+			)
+		`, in.UserID).Scan(&finished).Error; err != nil {
+			return err
+		}
+		if finished {
+			return ErrNewCustomerOnly
+		}
+	}
+
+	return nil
+}
+
+// checkRules aturan voucher yang tidak butuh data pemakaian user: periode,
+// kuota, target layanan, minimum order
+func checkRules(v *voucherRow, in EligibilityInput) error {
+	if !v.IsActive || !v.InPeriod {
+		return ErrNotEligible
+	}
+
+	if v.MaxUsage != nil && v.UsedCount+v.ReservedCount >= *v.MaxUsage {
+		return ErrQuotaExhausted
+	}
+
+	if v.JobCategoryID != nil && *v.JobCategoryID != in.JobCategoryID {
+		return ErrWrongService
+	}
+	if v.JobSubCategoryID != nil && *v.JobSubCategoryID != in.JobSubCategoryID {
+		return ErrWrongService
+	}
+
+	if v.MinOrderValue != nil && in.Amount < *v.MinOrderValue {
+		return fmt.Errorf("%w (minimal Rp%.0f)", ErrMinOrder, *v.MinOrderValue)
+	}
+
+	return nil
+}
+
+// checkUserLimit used = pemakaian (histori + reservasi aktif) user ini;
+// per_user_limit < 1 dianggap 1
+func checkUserLimit(v *models.Voucher, used int64) error {
+	limit := v.PerUserLimit
+	if limit < 1 {
+		limit = 1
+	}
+	if used >= int64(limit) {
+		return ErrUsageLimitReached
+	}
+	return nil
+}
+
+// Discount potongan voucher PERCENT (dibatasi max_discount) / FIXED,
+// maksimal sebesar amount
+func Discount(v *models.Voucher, amount float64) float64 {
+	var discount float64
+	switch strings.ToUpper(v.DiscountType) {
+	case DiscountPercent:
+		discount = math.Round(amount * v.DiscountValue / 100)
+		if v.MaxDiscount != nil && *v.MaxDiscount > 0 && discount > *v.MaxDiscount {
+			discount = *v.MaxDiscount
+		}
+	default:
+		discount = v.DiscountValue
+	}
+
+	if discount > amount {
+		discount = amount
+	}
+	if discount < 0 {
+		discount = 0
+	}
+	return discount
+}
+
+// Reserve pegang kuota voucher untuk customer request. Dipanggil di dalam
+// transaksi pembuatan request; baris voucher dikunci sehingga cek kuota dan
+// batas per user aman untuk pemakaian bersamaan.
+func Reserve(tx *gorm.DB, in EligibilityInput, requestID int64, discount float64) error {
+	v, err := loadVoucher(tx, in, true)
+	if err != nil {
+		return err
+	}
+
+	if err := validate(tx, v, in); err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`
+		UPDATE vouchers
+		SET reserved_count = reserved_count + 1,
+		    updated_at = NOW()
+		WHERE id = ?
+	`, v.ID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		INSERT INTO voucher_reservations (voucher_id, user_id, request_id, discount_value, status)
+		VALUES (?, ?, ?, ?, ?)
+	`, v.ID, in.UserID, requestID, discount, ReservationReserved).Error
+}
+
+// Redeem catat pemakaian voucher request saat order selesai dibayar.
+// Dipanggil di dalam transaksi penyelesaian order. No-op jika request
+// tidak memakai voucher.
+func Redeem(tx *gorm.DB, requestID, orderID int64) error {
+	var r struct {
+		VoucherID     int64
+		UserID        int64
+		DiscountValue float64
+	}
+
+	res := tx.Raw(`
+		UPDATE voucher_reservations
+		SET status = ?, order_id = ?, updated_at = NOW()
+		WHERE request_id = ? AND status = ?
+		RETURNING voucher_id, user_id, discount_value
+	`, ReservationRedeemed, orderID, requestID, ReservationReserved).Scan(&r)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	if err := tx.Exec(`
+		UPDATE vouchers
+		SET reserved_count = GREATEST(reserved_count - 1, 0),
+		    used_count = used_count + 1,
+		    updated_at = NOW()
+		WHERE id = ?
+	`, r.VoucherID).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		INSERT INTO voucher_histories (voucher_id, user_id, order_id, discount_value, used_at)
+		VALUES (?, ?, ?, ?, NOW())
+	`, r.VoucherID, r.UserID, orderID, r.DiscountValue).Error
+}
+
+// Release kembalikan kuota voucher request yang batal / expired.
+// No-op jika tidak ada reservasi aktif.
+func Release(tx *gorm.DB, requestID int64) error {
+	var voucherID int64
+
+	res := tx.Raw(`
+		UPDATE voucher_reservations
+		SET status = ?, updated_at = NOW()
+		WHERE request_id = ? AND status = ?
+		RETURNING voucher_id
+	`, ReservationReleased, requestID, ReservationReserved).Scan(&voucherID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	return tx.Exec(`
+		UPDATE vouchers
+		SET reserved_count = GREATEST(reserved_count - 1, 0),
+		    updated_at = NOW()
+		WHERE id = ?
+	`, voucherID).Error
+}
@@ -0,0 +1,218 @@
+package voucher
+
+import (
+	"errors"
+	"testing"
+
+	"teka-api/internal/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestDiscount(t *testing.T) {
+	tests := []struct {
+		name    string
+		voucher models.Voucher
+		amount  float64
+		want    float64
+	}{
+		{
+			name:    "percent",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 10},
+			amount:  150000,
+			want:    15000,
+		},
+		{
+			name:    "percent lowercase type",
+			voucher: models.Voucher{DiscountType: "percent", DiscountValue: 10},
+			amount:  150000,
+			want:    15000,
+		},
+		{
+			name:    "percent rounded",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 15},
+			amount:  99999,
+			want:    15000,
+		},
+		{
+			name:    "percent capped by max_discount",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 50, MaxDiscount: ptr(25000.0)},
+			amount:  200000,
+			want:    25000,
+		},
+		{
+			name:    "percent below max_discount",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 10, MaxDiscount: ptr(25000.0)},
+			amount:  200000,
+			want:    20000,
+		},
+		{
+			name:    "percent with zero max_discount is uncapped",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 50, MaxDiscount: ptr(0.0)},
+			amount:  200000,
+			want:    100000,
+		},
+		{
+			name:    "fixed",
+			voucher: models.Voucher{DiscountType: DiscountFixed, DiscountValue: 20000},
+			amount:  150000,
+			want:    20000,
+		},
+		{
+			name:    "fixed ignores max_discount",
+			voucher: models.Voucher{DiscountType: DiscountFixed, DiscountValue: 20000, MaxDiscount: ptr(5000.0)},
+			amount:  150000,
+			want:    20000,
+		},
+		{
+			name:    "fixed capped by amount",
+			voucher: models.Voucher{DiscountType: DiscountFixed, DiscountValue: 200000},
+			amount:  150000,
+			want:    150000,
+		},
+		{
+			name:    "percent over 100 capped by amount",
+			voucher: models.Voucher{DiscountType: DiscountPercent, DiscountValue: 150},
+			amount:  100000,
+			want:    100000,
+		},
+		{
+			name:    "negative value",
+			voucher: models.Voucher{DiscountType: DiscountFixed, DiscountValue: -5000},
+			amount:  100000,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Discount(&tt.voucher, tt.amount); got != tt.want {
+				t.Fatalf("Discount = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	active := func(v models.Voucher) *voucherRow {
+		v.IsActive = true
+		return &voucherRow{Voucher: v, InPeriod: true}
+	}
+	in := EligibilityInput{UserID: 1, JobCategoryID: 3, JobSubCategoryID: 7, Amount: 100000}
+
+	tests := []struct {
+		name    string
+		voucher *voucherRow
+		in      EligibilityInput
+		wantErr error
+	}{
+		{
+			name:    "eligible",
+			voucher: active(models.Voucher{}),
+			in:      in,
+		},
+		{
+			name:    "inactive",
+			voucher: &voucherRow{Voucher: models.Voucher{IsActive: false}, InPeriod: true},
+			in:      in,
+			wantErr: ErrNotEligible,
+		},
+		{
+			name:    "out of period",
+			voucher: &voucherRow{Voucher: models.Voucher{IsActive: true}, InPeriod: false},
+			in:      in,
+			wantErr: ErrNotEligible,
+		},
+		{
+			name:    "quota used up",
+			voucher: active(models.Voucher{MaxUsage: ptr(10), UsedCount: 10}),
+			in:      in,
+			wantErr: ErrQuotaExhausted,
+		},
+		{
+			name:    "quota held by reservations",
+			voucher: active(models.Voucher{MaxUsage: ptr(10), UsedCount: 7, ReservedCount: 3}),
+			in:      in,
+			wantErr: ErrQuotaExhausted,
+		},
+		{
+			name:    "quota left",
+			voucher: active(models.Voucher{MaxUsage: ptr(10), UsedCount: 7, ReservedCount: 2}),
+			in:      in,
+		},
+		{
+			name:    "wrong category",
+			voucher: active(models.Voucher{JobCategoryID: ptr(int64(4))}),
+			in:      in,
+			wantErr: ErrWrongService,
+		},
+		{
+			name:    "wrong sub category",
+			voucher: active(models.Voucher{JobCategoryID: ptr(int64(3)), JobSubCategoryID: ptr(int64(8))}),
+			in:      in,
+			wantErr: ErrWrongService,
+		},
+		{
+			name:    "matching category and sub category",
+			voucher: active(models.Voucher{JobCategoryID: ptr(int64(3)), JobSubCategoryID: ptr(int64(7))}),
+			in:      in,
+		},
+		{
+			name:    "below min order",
+			voucher: active(models.Voucher{MinOrderValue: ptr(150000.0)}),
+			in:      in,
+			wantErr: ErrMinOrder,
+		},
+		{
+			name:    "exactly min order",
+			voucher: active(models.Voucher{MinOrderValue: ptr(100000.0)}),
+			in:      in,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRules(tt.voucher, tt.in)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckUserLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		perUserLimit int
+		used         int64
+		wantErr      bool
+	}{
+		{name: "first use", perUserLimit: 1, used: 0},
+		{name: "single use already used", perUserLimit: 1, used: 1, wantErr: true},
+		{name: "zero limit treated as one", perUserLimit: 0, used: 0},
+		{name: "zero limit treated as one, used", perUserLimit: 0, used: 1, wantErr: true},
+		{name: "multi use left", perUserLimit: 3, used: 2},
+		{name: "multi use reached", perUserLimit: 3, used: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUserLimit(&models.Voucher{PerUserLimit: tt.perUserLimit}, tt.used)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUsageLimitReached) {
+					t.Fatalf("err = %v, want %v", err, ErrUsageLimitReached)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
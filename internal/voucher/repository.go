@@ -1,6 +1,9 @@
 package voucher
 
 import (
+	"errors"
+	"strings"
+
 	"teka-api/internal/models"
 
 	"gorm.io/gorm"
@@ -9,6 +12,14 @@ import (
 type Repository interface {
 	GetActiveVouchers() ([]models.Voucher, error)
 	GetAvailableVouchers(userID uint) ([]models.Voucher, error)
+
+	GetAll(batchCode string) ([]models.Voucher, error)
+	GetByID(id uint) (*models.Voucher, error)
+	Create(v *models.Voucher) error
+	Update(v *models.Voucher) error
+	Delete(id uint) (deactivated bool, err error)
+	CreateBatch(vouchers []models.Voucher) error
+	ExistingCodes(codes []string) ([]string, error)
 }
 
 type repository struct {
@@ -26,26 +37,144 @@ func (r *repository) GetActiveVouchers() ([]models.Voucher, error) {
 	err := r.db.
 		Where("is_active = true").
 		Where("CURRENT_DATE BETWEEN start_date AND end_date").
-		Where("(max_usage IS NULL OR used_count < max_usage)").
+		Where("(max_usage IS NULL OR used_count + reserved_count < max_usage)").
 		Find(&vouchers).Error
 
 	return vouchers, err
 }
 
+// GetAll semua voucher (termasuk nonaktif); batchCode kosong = tanpa kode bulk
+func (r *repository) GetAll(batchCode string) ([]models.Voucher, error) {
+	var vouchers []models.Voucher
+
+	q := r.db.Order("id DESC")
+	if batchCode != "" {
+		q = q.Where("batch_code = ?", batchCode)
+	} else {
+		q = q.Where("batch_code IS NULL")
+	}
+
+	err := q.Find(&vouchers).Error
+	return vouchers, err
+}
+
+func (r *repository) GetByID(id uint) (*models.Voucher, error) {
+	var v models.Voucher
+
+	if err := r.db.Where("id = ?", id).First(&v).Error; err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+func (r *repository) Create(v *models.Voucher) error {
+	return r.db.Create(v).Error
+}
+
+// Update simpan field yang bisa diubah admin; counter pemakaian tidak disentuh
+func (r *repository) Update(v *models.Voucher) error {
+	return r.db.Model(&models.Voucher{}).
+		Where("id = ?", v.ID).
+		Select(
+			"code", "name", "description", "discount_type", "discount_value",
+			"start_date", "end_date", "is_active", "max_usage",
+			"job_category_id", "job_sub_category_id", "min_order_value", "max_discount",
+			"per_user_limit", "new_customer_only", "updated_at",
+		).
+		Updates(v).Error
+}
+
+// Delete hapus voucher yang belum pernah dipakai / direservasi; voucher yang
+// sudah punya riwayat hanya dinonaktifkan supaya histori tetap utuh
+func (r *repository) Delete(id uint) (bool, error) {
+	deactivated := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var v models.Voucher
+		if err := tx.Raw(`SELECT * FROM vouchers WHERE id = ? FOR UPDATE`, id).Scan(&v).Error; err != nil {
+			return err
+		}
+		if v.ID == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var used bool
+		if err := tx.Raw(`
+			SELECT EXISTS (SELECT 1 FROM voucher_histories WHERE voucher_id = ?)
+			    OR EXISTS (SELECT 1 FROM voucher_reservations WHERE voucher_id = ?)
+		`, id, id).Scan(&used).Error; err != nil {
+			return err
+		}
+
+		if used {
+			deactivated = true
+			return tx.Exec(`
+				UPDATE vouchers SET is_active = false, updated_at = NOW() WHERE id = ?
+			`, id).Error
+		}
+
+		return tx.Exec(`DELETE FROM vouchers WHERE id = ?`, id).Error
+	})
+
+	return deactivated, err
+}
+
+// CreateBatch insert kode bulk dalam satu transaksi
+func (r *repository) CreateBatch(vouchers []models.Voucher) error {
+	if len(vouchers) == 0 {
+		return errors.New("tidak ada voucher untuk dibuat")
+	}
+	return r.db.CreateInBatches(&vouchers, 500).Error
+}
+
+// ExistingCodes kode dari daftar yang sudah terpakai voucher lain
+func (r *repository) ExistingCodes(codes []string) ([]string, error) {
+	upper := make([]string, len(codes))
+	for i, c := range codes {
+		upper[i] = strings.ToUpper(c)
+	}
+
+	var existing []string
+	err := r.db.Raw(`
+		SELECT UPPER(code) FROM vouchers WHERE UPPER(code) IN ?
+	`, upper).Scan(&existing).Error
+
+	return existing, err
+}
+
 // USER
+// GetAvailableVouchers voucher publik yang masih bisa dipakai user; kode
+// bulk (sekali pakai) tidak ditampilkan
 func (r *repository) GetAvailableVouchers(userID uint) ([]models.Voucher, error) {
 	var vouchers []models.Voucher
 
 	err := r.db.
 		Where("is_active = true").
+		Where("batch_code IS NULL").
 		Where("CURRENT_DATE BETWEEN start_date AND end_date").
-		Where("(max_usage IS NULL OR used_count < max_usage)").
+		Where("(max_usage IS NULL OR used_count + reserved_count < max_usage)").
 		Where(`
-			NOT EXISTS (
-				SELECT 1
+			(
+				SELECT COUNT(*)
 				FROM voucher_histories vh
 				WHERE vh.voucher_id = vouchers.id
 				  AND vh.user_id = ?
+			) + (
+				SELECT COUNT(*)
+				FROM voucher_reservations vr
+				WHERE vr.voucher_id = vouchers.id
+				  AND vr.user_id = ?
+				  AND vr.status = ?
+			) < GREATEST(per_user_limit, 1)
+		`, userID, userID, ReservationReserved).
+		Where(`
+			(
+				new_customer_only = false
+				OR NOT EXISTS (
+					SELECT 1 FROM service_orders so
+					WHERE so.customer_id = ? AND so.status_id = 6
+				)
 			)
 		`, userID).
 		Find(&vouchers).Error
@@ -18,6 +18,13 @@ func Routes(app *fiber.App, db *gorm.DB) {
 	api.Get("/vouchers", middleware.JWTProtected(), handler.GetUserVouchers)
 
 	// ADMIN
-	admin := api.Group("/admin", middleware.JWTProtected())
+	// hanya admin: CRUD voucher & generate kode massal
+	admin := api.Group("/admin", middleware.JWTProtected(), middleware.AdminOnly(db))
 	admin.Get("/vouchers", handler.GetAdminVouchers)
+	admin.Get("/vouchers/all", handler.GetAllVouchers)
+	admin.Post("/vouchers/bulk", handler.BulkGenerateVouchers)
+	admin.Get("/vouchers/:id", handler.GetVoucher)
+	admin.Post("/vouchers", handler.CreateVoucher)
+	admin.Put("/vouchers/:id", handler.UpdateVoucher)
+	admin.Delete("/vouchers/:id", handler.DeleteVoucher)
 }
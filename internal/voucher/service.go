@@ -1,10 +1,25 @@
 package voucher
 
-import "teka-api/internal/models"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"teka-api/internal/models"
+	"teka-api/pkg/utils"
+)
 
 type Service interface {
 	GetActiveVouchers() ([]models.Voucher, error)
 	GetAvailableVouchers(userID uint) ([]models.Voucher, error)
+
+	GetAll(batchCode string) ([]models.Voucher, error)
+	GetByID(id uint) (*models.Voucher, error)
+	Create(in VoucherInput) (*models.Voucher, error)
+	Update(id uint, in VoucherInput) (*models.Voucher, error)
+	Delete(id uint) (deactivated bool, err error)
+	BulkGenerate(in BulkGenerateInput) (*BulkGenerateResult, error)
 }
 
 type service struct {
@@ -15,6 +30,12 @@ func NewService(repo Repository) Service {
 	return &service{repo: repo}
 }
 
+const (
+	bulkMaxQuantity = 5000
+	bulkCodeLength  = 8
+	bulkMaxAttempts = 5
+)
+
 func (s *service) GetActiveVouchers() ([]models.Voucher, error) {
 	return s.repo.GetActiveVouchers()
 }
@@ -22,3 +43,263 @@ func (s *service) GetActiveVouchers() ([]models.Voucher, error) {
 func (s *service) GetAvailableVouchers(userID uint) ([]models.Voucher, error) {
 	return s.repo.GetAvailableVouchers(userID)
 }
+
+func (s *service) GetAll(batchCode string) ([]models.Voucher, error) {
+	return s.repo.GetAll(strings.TrimSpace(batchCode))
+}
+
+func (s *service) GetByID(id uint) (*models.Voucher, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *service) Create(in VoucherInput) (*models.Voucher, error) {
+	v := &models.Voucher{IsActive: true}
+	if err := applyInput(v, in); err != nil {
+		return nil, err
+	}
+	if v.Code == "" {
+		return nil, errors.New("code wajib diisi")
+	}
+
+	if err := s.ensureCodeFree(v.Code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	v.CreatedAt = now
+	v.UpdatedAt = now
+
+	if err := s.repo.Create(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *service) Update(id uint, in VoucherInput) (*models.Voucher, error) {
+	v, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	oldCode := v.Code
+	if err := applyInput(v, in); err != nil {
+		return nil, err
+	}
+	if v.Code == "" {
+		v.Code = oldCode
+	}
+
+	// kode bulk tidak boleh diganti, unik per customer
+	if v.BatchCode != nil && !strings.EqualFold(v.Code, oldCode) {
+		return nil, errors.New("kode voucher bulk tidak bisa diubah")
+	}
+
+	// kode bulk selalu sekali pakai; max_usage kosong = tanpa batas
+	if v.BatchCode != nil {
+		if (in.MaxUsage != nil && *in.MaxUsage != 1) || in.PerUserLimit > 1 {
+			return nil, errors.New("voucher bulk hanya bisa dipakai sekali")
+		}
+		one := 1
+		v.MaxUsage = &one
+		v.PerUserLimit = 1
+	}
+	if !strings.EqualFold(v.Code, oldCode) {
+		if err := s.ensureCodeFree(v.Code); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.MaxUsage != nil && *v.MaxUsage < v.UsedCount+v.ReservedCount {
+		return nil, fmt.Errorf("max_usage tidak boleh kurang dari pemakaian saat ini (%d)", v.UsedCount+v.ReservedCount)
+	}
+
+	v.UpdatedAt = time.Now()
+	if err := s.repo.Update(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *service) Delete(id uint) (bool, error) {
+	return s.repo.Delete(id)
+}
+
+// BulkGenerate buat quantity voucher kode unik sekali pakai (max_usage = 1,
+// per_user_limit = 1) dengan aturan yang sama dari template
+func (s *service) BulkGenerate(in BulkGenerateInput) (*BulkGenerateResult, error) {
+	if in.Quantity < 1 || in.Quantity > bulkMaxQuantity {
+		return nil, fmt.Errorf("quantity harus antara 1 dan %d", bulkMaxQuantity)
+	}
+
+	tmpl := models.Voucher{IsActive: true}
+	if err := applyInput(&tmpl, in.VoucherInput); err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ToUpper(strings.TrimSpace(in.Prefix))
+	batch, err := utils.RandomCode("B", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.uniqueCodes(prefix, in.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	one := 1
+	now := time.Now()
+	vouchers := make([]models.Voucher, len(codes))
+	for i, code := range codes {
+		v := tmpl
+		v.Code = code
+		v.MaxUsage = &one
+		v.PerUserLimit = 1
+		v.BatchCode = &batch
+		v.CreatedAt = now
+		v.UpdatedAt = now
+		vouchers[i] = v
+	}
+
+	if err := s.repo.CreateBatch(vouchers); err != nil {
+		return nil, err
+	}
+
+	return &BulkGenerateResult{BatchCode: batch, Codes: codes}, nil
+}
+
+// uniqueCodes generate kode acak, ulangi untuk kode yang bentrok dengan
+// voucher lain. Unique index uq_vouchers_code tetap jadi pengaman terakhir.
+func (s *service) uniqueCodes(prefix string, n int) ([]string, error) {
+	seen := make(map[string]bool, n)
+	codes := make([]string, 0, n)
+
+	for attempt := 0; attempt < bulkMaxAttempts && len(codes) < n; attempt++ {
+		var batch []string
+		for len(codes)+len(batch) < n {
+			code, err := utils.RandomCode(prefix, bulkCodeLength)
+			if err != nil {
+				return nil, err
+			}
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			batch = append(batch, code)
+		}
+
+		existing, err := s.repo.ExistingCodes(batch)
+		if err != nil {
+			return nil, err
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, c := range existing {
+			taken[c] = true
+		}
+
+		for _, c := range batch {
+			if !taken[c] {
+				codes = append(codes, c)
+			}
+		}
+	}
+
+	if len(codes) < n {
+		return nil, errors.New("gagal generate kode unik, coba prefix lain")
+	}
+	return codes, nil
+}
+
+func (s *service) ensureCodeFree(code string) error {
+	existing, err := s.repo.ExistingCodes([]string{code})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return errors.New("kode voucher sudah dipakai")
+	}
+	return nil
+}
+
+// applyInput validasi input admin lalu salin ke voucher
+func applyInput(v *models.Voucher, in VoucherInput) error {
+	if code := strings.ToUpper(strings.TrimSpace(in.Code)); code != "" {
+		v.Code = code
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		return errors.New("name wajib diisi")
+	}
+	v.Name = strings.TrimSpace(in.Name)
+	v.Description = in.Description
+
+	switch t := strings.ToUpper(strings.TrimSpace(in.DiscountType)); t {
+	case DiscountPercent, DiscountFixed:
+		v.DiscountType = t
+	default:
+		return errors.New("discount_type harus PERCENT atau FIXED")
+	}
+
+	if in.DiscountValue <= 0 {
+		return errors.New("discount_value harus lebih dari 0")
+	}
+	if v.DiscountType == DiscountPercent && in.DiscountValue > 100 {
+		return errors.New("discount_value PERCENT maksimal 100")
+	}
+	v.DiscountValue = in.DiscountValue
+
+	start, err := time.Parse("2006-01-02", in.StartDate)
+	if err != nil {
+		return errors.New("start_date harus format YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", in.EndDate)
+	if err != nil {
+		return errors.New("end_date harus format YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return errors.New("end_date tidak boleh sebelum start_date")
+	}
+	v.StartDate = start
+	v.EndDate = end
+
+	if in.IsActive != nil {
+		v.IsActive = *in.IsActive
+	}
+
+	if in.MaxUsage != nil && *in.MaxUsage < 1 {
+		return errors.New("max_usage minimal 1")
+	}
+	v.MaxUsage = in.MaxUsage
+
+	if in.JobSubCategoryID != nil && in.JobCategoryID == nil {
+		return errors.New("job_category_id wajib diisi jika job_sub_category_id diisi")
+	}
+	v.JobCategoryID = in.JobCategoryID
+	v.JobSubCategoryID = in.JobSubCategoryID
+
+	if in.MinOrderValue != nil && *in.MinOrderValue < 0 {
+		return errors.New("min_order_value tidak boleh negatif")
+	}
+	v.MinOrderValue = in.MinOrderValue
+
+	if in.MaxDiscount != nil {
+		if v.DiscountType != DiscountPercent {
+			return errors.New("max_discount hanya untuk voucher PERCENT")
+		}
+		if *in.MaxDiscount <= 0 {
+			return errors.New("max_discount harus lebih dari 0")
+		}
+	}
+	v.MaxDiscount = in.MaxDiscount
+
+	if in.PerUserLimit < 0 {
+		return errors.New("per_user_limit tidak boleh negatif")
+	}
+	v.PerUserLimit = in.PerUserLimit
+	if v.PerUserLimit == 0 {
+		v.PerUserLimit = 1
+	}
+
+	v.NewCustomerOnly = in.NewCustomerOnly
+
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"teka-api/internal/realtime"
 	"teka-api/internal/realtime/firebase"
 	"teka-api/internal/realtime/redis"
+	"teka-api/internal/referral"
 	"teka-api/internal/screens"
 	"teka-api/internal/voucher"
 	"teka-api/pkg/database"
@@ -41,6 +42,9 @@ func main() {
 	var minioClient *minio.Client
 	minioClient = utils.InitMinio()
 	orderHub := dokter.NewOrderHub()
+	userHub := dokter.NewUserHub()
+	requestHub := dokter.NewRequestHub()
+	offerHub := dokter.NewUserHub()
 
 	// 4️⃣ Fiber with proper configuration for production
 	app := fiber.New(fiber.Config{
@@ -81,6 +85,7 @@ func main() {
 	app.Static("/assets", "./assets")
 	address.RegisterRoutes(app)
 	voucher.Routes(app, db)
+	referral.Routes(app, db)
 	job_tarif.Routes(app, db)
 
 	// Screens
@@ -91,7 +96,7 @@ func main() {
 
 	// Dokter (PAKAI MinIO)
 	dokterRepo := dokter.NewRepository(db)
-	dokterService := dokter.NewService(dokterRepo, orderHub)
+	dokterService := dokter.NewService(dokterRepo, orderHub, userHub, requestHub, offerHub)
 	dokterHandler := dokter.NewHandler(*dokterService, minioClient, orderHub)
 	dokter.RegisterRoutes(app, dokterHandler)
 
@@ -113,8 +118,23 @@ func main() {
 	realtime.RegisterRoutes(app)
 
 	// 🔥 START DISPATCH WORKER
-	go dokterService.RunOfferTimeoutWorker(context.Background())
-	go dokterService.RunAutoOrderCompletionWorker(context.Background())
+	// tiap worker hanya jalan di satu replica (Postgres advisory lock)
+	workerCtx := context.Background()
+
+	// event websocket lintas replica (worker hanya jalan di leader)
+	wsFanout := dokter.NewWSFanout()
+	wsFanout.Register("orders", orderHub)
+	wsFanout.Register("users", userHub)
+	wsFanout.Register("requests", requestHub)
+	wsFanout.Register("offers", offerHub)
+	go wsFanout.Run(workerCtx)
+
+	go database.RunAsLeader(workerCtx, db, "dokter_offer_timeout_worker", dokterService.RunOfferTimeoutWorker)
+	go database.RunAsLeader(workerCtx, db, "dokter_auto_order_completion_worker", dokterService.RunAutoOrderCompletionWorker)
+	go database.RunAsLeader(workerCtx, db, "dokter_scheduled_booking_worker", dokterService.RunScheduledBookingWorker)
+	go database.RunAsLeader(workerCtx, db, "dokter_availability_worker", dokterService.RunAvailabilityWorker)
+	go database.RunAsLeader(workerCtx, db, "dokter_license_expiry_worker", dokterService.RunLicenseExpiryWorker)
+	go database.RunAsLeader(workerCtx, db, "dokter_upload_session_expiry_worker", dokterHandler.RunUploadSessionExpiryWorker)
 
 	// Healthcheck
 	app.Get("/kaithheathcheck", func(c *fiber.Ctx) error {
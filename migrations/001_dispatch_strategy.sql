@@ -0,0 +1,10 @@
+-- Strategi dispatch offer ke mitra.
+-- DISPATCH_MODE      : SEQUENTIAL | FANOUT | WAVE
+-- DISPATCH_BATCH_SIZE: jumlah mitra per gelombang (FANOUT / WAVE)
+-- Override per kategori: DISPATCH_MODE_CAT_<job_category_id>, DISPATCH_BATCH_SIZE_CAT_<job_category_id>
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('DISPATCH_MODE', 'Mode dispatch offer ke mitra (SEQUENTIAL/FANOUT/WAVE)', 'SEQUENTIAL', true, 'system', 'system'),
+	('DISPATCH_BATCH_SIZE', 'Jumlah mitra per gelombang dispatch', '3', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Alasan dokter menolak offer (POST /api/dokter/offers/:id/reject)
+ALTER TABLE request_mitra_offers
+	ADD COLUMN IF NOT EXISTS reject_reason_code VARCHAR(32),
+	ADD COLUMN IF NOT EXISTS reject_reason_note TEXT;
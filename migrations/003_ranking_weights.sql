@@ -0,0 +1,12 @@
+-- Bobot skor ranking kandidat mitra di SearchDoctors
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('RANK_WEIGHT_DISTANCE', 'Bobot jarak pada ranking mitra', '0.40', true, 'system', 'system'),
+	('RANK_WEIGHT_RATING', 'Bobot rata-rata rating pada ranking mitra', '0.25', true, 'system', 'system'),
+	('RANK_WEIGHT_REVIEWS', 'Bobot jumlah review pada ranking mitra', '0.10', true, 'system', 'system'),
+	('RANK_WEIGHT_ACCEPTANCE', 'Bobot acceptance rate offer pada ranking mitra', '0.15', true, 'system', 'system'),
+	('RANK_WEIGHT_CANCELLATION', 'Bobot pengurang pembatalan 30 hari terakhir', '0.10', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_mitra_ratings_mitra_id ON mitra_ratings (mitra_id);
+CREATE INDEX IF NOT EXISTS idx_request_mitra_offers_mitra_status ON request_mitra_offers (mitra_id, status_id);
@@ -0,0 +1,10 @@
+-- Perluasan radius otomatis saat antrian offer habis
+-- customer_requests.status_id = 5 → expired (tidak ada dokter ditemukan)
+ALTER TABLE customer_requests
+	ADD COLUMN IF NOT EXISTS search_radius NUMERIC(8,2);
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('RADIUS_EXPANSION_STEP_KM', 'Kenaikan radius pencarian saat antrian habis (km)', '5', true, 'system', 'system'),
+	('RADIUS_EXPANSION_MAX_KM', 'Batas maksimal radius perluasan pencarian (km)', '20', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,18 @@
+-- Booking kunjungan terjadwal
+-- customer_requests.status_id = 6 → scheduled (menunggu waktu dispatch)
+ALTER TABLE customer_requests
+	ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_customer_requests_scheduled
+	ON customer_requests (status_id, scheduled_at)
+	WHERE scheduled_at IS NOT NULL;
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('BOOKING_MIN_LEAD_MINUTES', 'Minimal jeda booking dari waktu sekarang (menit)', '60', true, 'system', 'system'),
+	('BOOKING_MAX_DAYS_AHEAD', 'Maksimal booking ke depan (hari)', '30', true, 'system', 'system'),
+	('BOOKING_SLOT_MINUTES', 'Lebar slot jadwal booking (menit)', '60', true, 'system', 'system'),
+	('BOOKING_DISPATCH_LEAD_MINUTES', 'Dispatch offer dimulai X menit sebelum jadwal', '30', true, 'system', 'system'),
+	('BOOKING_REMINDER_MINUTES', 'Reminder dikirim X menit sebelum jadwal', '15', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,13 @@
+-- mitra_details.availability_status_id: 1 = offline, 2 = online
+-- Jadwal jam kerja mingguan mitra (WIB), day_of_week 0 = Minggu
+CREATE TABLE IF NOT EXISTS mitra_working_hours (
+	id          BIGSERIAL PRIMARY KEY,
+	user_id     BIGINT   NOT NULL REFERENCES users(id),
+	day_of_week SMALLINT NOT NULL CHECK (day_of_week BETWEEN 0 AND 6),
+	start_time  TIME     NOT NULL,
+	end_time    TIME     NOT NULL CHECK (end_time > start_time),
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mitra_working_hours_user ON mitra_working_hours (user_id, day_of_week);
@@ -0,0 +1,10 @@
+-- Posisi terkini mitra dari heartbeat lokasi (REST / websocket)
+ALTER TABLE mitra_details
+	ADD COLUMN IF NOT EXISTS current_latitude DOUBLE PRECISION,
+	ADD COLUMN IF NOT EXISTS current_longitude DOUBLE PRECISION,
+	ADD COLUMN IF NOT EXISTS location_updated_at TIMESTAMPTZ;
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('LOCATION_STALE_SECONDS', 'Batas umur heartbeat lokasi mitra untuk matching (detik)', '300', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
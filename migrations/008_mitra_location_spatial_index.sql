@@ -0,0 +1,23 @@
+-- Kolom point (x = longitude, y = latitude) + GiST index untuk prefilter
+-- bounding box di SearchDoctors, diisi bersamaan dengan heartbeat lokasi
+ALTER TABLE mitra_details
+	ADD COLUMN IF NOT EXISTS current_location POINT;
+
+UPDATE mitra_details
+SET current_location = point(current_longitude, current_latitude)
+WHERE current_location IS NULL
+  AND current_latitude IS NOT NULL
+  AND current_longitude IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mitra_details_current_location
+	ON mitra_details USING GIST (current_location)
+	WHERE availability_status_id = 2;
+
+-- NOT EXISTS order aktif / offer sebelumnya per kandidat
+-- (offer aktif sudah tercover idx_request_mitra_offers_mitra_status)
+CREATE INDEX IF NOT EXISTS idx_service_orders_active_mitra
+	ON service_orders (mitra_id)
+	WHERE status_id IN (1,2,3);
+
+CREATE INDEX IF NOT EXISTS idx_request_mitra_offers_request_mitra
+	ON request_mitra_offers (request_id, mitra_id);
@@ -0,0 +1,25 @@
+-- Waktu kadaluarsa offer disimpan saat offer dikirim, dipakai scheduler
+-- expiry (timer tepat di expired_at, bukan polling)
+ALTER TABLE request_mitra_offers
+	ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ;
+
+UPDATE request_mitra_offers
+SET expired_at = sent_at + (
+	COALESCE((
+		SELECT NULLIF(parameter_value, '')::int
+		FROM global_parameter
+		WHERE parameter_code = 'OFFER_TIMEOUT_SECONDS' AND is_active = true
+	), 60) * INTERVAL '1 second'
+)
+WHERE status_id = 1
+  AND sent_at IS NOT NULL
+  AND expired_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_request_mitra_offers_waiting_expiry
+	ON request_mitra_offers (expired_at)
+	WHERE status_id = 1;
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('OFFER_TIMEOUT_SECONDS', 'Batas waktu mitra merespon offer (detik)', '60', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,29 @@
+-- Pembatalan service order setelah diterima dokter (oleh customer / mitra)
+ALTER TABLE service_orders
+	ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(10),
+	ADD COLUMN IF NOT EXISTS cancel_reason_code VARCHAR(30),
+	ADD COLUMN IF NOT EXISTS cancel_reason_note TEXT,
+	ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS cancellation_fee BIGINT NOT NULL DEFAULT 0;
+
+-- Kategori mutasi saldo pembatalan
+INSERT INTO saldo_transaction_categories (id, code)
+VALUES
+	(5, 'CANCELLATION_FEE'),
+	(6, 'CANCELLATION_COMPENSATION')
+ON CONFLICT (id) DO NOTHING;
+
+-- Biaya pembatalan per status order saat dibatalkan (rupiah).
+-- Customer: dipotong dari saldo customer, CANCEL_FEE_MITRA_SHARE_PERCENT diteruskan ke mitra.
+-- Mitra: penalti dipotong dari saldo mitra.
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('CANCEL_FEE_CUSTOMER_ACCEPTED', 'Biaya batal customer setelah dokter menerima order', '0', true, 'system', 'system'),
+	('CANCEL_FEE_CUSTOMER_OTW', 'Biaya batal customer saat dokter OTW', '15000', true, 'system', 'system'),
+	('CANCEL_FEE_CUSTOMER_ARRIVED', 'Biaya batal customer saat dokter sudah sampai', '30000', true, 'system', 'system'),
+	('CANCEL_FEE_MITRA_ACCEPTED', 'Penalti batal dokter setelah menerima order', '0', true, 'system', 'system'),
+	('CANCEL_FEE_MITRA_OTW', 'Penalti batal dokter saat OTW', '10000', true, 'system', 'system'),
+	('CANCEL_FEE_MITRA_ARRIVED', 'Penalti batal dokter saat sudah sampai', '10000', true, 'system', 'system'),
+	('CANCEL_FEE_MITRA_SHARE_PERCENT', 'Persentase biaya batal customer yang diteruskan ke dokter', '100', true, 'system', 'system'),
+	('CANCEL_AUTO_REDISPATCH', 'Cari dokter pengganti otomatis saat dokter membatalkan (true/false)', 'true', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
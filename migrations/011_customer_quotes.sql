@@ -0,0 +1,32 @@
+-- Quotation harga dihitung server (tarif + parameter + voucher), dipakai
+-- sekali oleh search / booking lewat quote_id bertanda tangan
+CREATE TABLE IF NOT EXISTS customer_quotes (
+	id                  VARCHAR(40) PRIMARY KEY,
+	customer_id         BIGINT NOT NULL,
+	job_category_id     BIGINT NOT NULL,
+	job_sub_category_id BIGINT,
+	job_tariff_id       BIGINT NOT NULL,
+	price               NUMERIC(14,2) NOT NULL,
+	platform_fee        NUMERIC(14,2) NOT NULL DEFAULT 0,
+	thr_bonus           NUMERIC(14,2) NOT NULL DEFAULT 0,
+	voucher_id          BIGINT,
+	voucher_value       NUMERIC(14,2) NOT NULL DEFAULT 0,
+	total               NUMERIC(14,2) NOT NULL,
+	expires_at          TIMESTAMPTZ NOT NULL,
+	used_at             TIMESTAMPTZ,
+	created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_quotes_customer ON customer_quotes (customer_id, created_at DESC);
+
+ALTER TABLE customer_requests
+	ADD COLUMN IF NOT EXISTS quote_id VARCHAR(40);
+
+-- Biaya tambahan per order (rupiah), bisa di-override per kategori
+-- dengan suffix _CAT_<job_category_id>
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('PLATFORM_FEE', 'Biaya platform per order (rupiah)', '0', true, 'system', 'system'),
+	('THR_BONUS', 'Bonus THR untuk mitra per order (rupiah)', '0', true, 'system', 'system'),
+	('QUOTE_TTL_SECONDS', 'Masa berlaku quotation harga (detik)', '300', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,28 @@
+-- Reservasi voucher per customer request: RESERVED saat request dibuat,
+-- REDEEMED saat order selesai dibayar, RELEASED saat batal / expired
+ALTER TABLE vouchers
+	ADD COLUMN IF NOT EXISTS reserved_count INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS voucher_reservations (
+	id             BIGSERIAL PRIMARY KEY,
+	voucher_id     BIGINT NOT NULL,
+	user_id        BIGINT NOT NULL,
+	request_id     BIGINT NOT NULL,
+	order_id       BIGINT,
+	discount_value NUMERIC(14,2) NOT NULL DEFAULT 0,
+	status         VARCHAR(10) NOT NULL DEFAULT 'RESERVED',
+	created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_voucher_reservations_request
+	ON voucher_reservations (request_id)
+	WHERE status IN ('RESERVED', 'REDEEMED');
+
+CREATE INDEX IF NOT EXISTS idx_voucher_reservations_voucher_user
+	ON voucher_reservations (voucher_id, user_id, status);
+
+ALTER TABLE voucher_histories
+	ADD COLUMN IF NOT EXISTS order_id BIGINT,
+	ADD COLUMN IF NOT EXISTS discount_value NUMERIC(14,2),
+	ADD COLUMN IF NOT EXISTS used_at TIMESTAMPTZ DEFAULT NOW();
@@ -0,0 +1,20 @@
+-- Aturan target voucher. Kolom NULL = tidak dibatasi.
+ALTER TABLE vouchers
+	ADD COLUMN IF NOT EXISTS job_category_id     BIGINT,
+	ADD COLUMN IF NOT EXISTS job_sub_category_id BIGINT,
+	ADD COLUMN IF NOT EXISTS min_order_value     NUMERIC(14,2),
+	ADD COLUMN IF NOT EXISTS max_discount        NUMERIC(14,2),
+	ADD COLUMN IF NOT EXISTS per_user_limit      INT NOT NULL DEFAULT 1,
+	ADD COLUMN IF NOT EXISTS new_customer_only   BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS batch_code          VARCHAR(40);
+
+-- kode voucher unik (case-insensitive), termasuk kode hasil bulk generate
+CREATE UNIQUE INDEX IF NOT EXISTS uq_vouchers_code
+	ON vouchers (UPPER(code));
+
+CREATE INDEX IF NOT EXISTS idx_vouchers_batch_code
+	ON vouchers (batch_code)
+	WHERE batch_code IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_voucher_histories_voucher_user
+	ON voucher_histories (voucher_id, user_id);
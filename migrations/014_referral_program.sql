@@ -0,0 +1,51 @@
+-- Kode referral per user + device saat registrasi (anti-abuse)
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16),
+	ADD COLUMN IF NOT EXISTS register_device_id VARCHAR(128);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_referral_code
+	ON users (referral_code)
+	WHERE referral_code IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_register_device
+	ON users (register_device_id)
+	WHERE register_device_id IS NOT NULL;
+
+ALTER TABLE temp_users
+	ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16),
+	ADD COLUMN IF NOT EXISTS device_id VARCHAR(128);
+
+-- Satu baris per referee. PENDING → REWARDED saat order pertama selesai,
+-- REJECTED jika gagal cek anti-abuse (reject_reason).
+CREATE TABLE IF NOT EXISTS referrals (
+	id              BIGSERIAL PRIMARY KEY,
+	referrer_id     BIGINT NOT NULL REFERENCES users(id),
+	referee_id      BIGINT NOT NULL REFERENCES users(id),
+	referral_code   VARCHAR(16) NOT NULL,
+	device_id       VARCHAR(128),
+	referee_phone   VARCHAR(20) NOT NULL, -- nomor ternormalisasi (08xxx)
+	status          VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+	reject_reason   VARCHAR(30),
+	order_id        BIGINT,
+	referrer_reward BIGINT NOT NULL DEFAULT 0,
+	referee_reward  BIGINT NOT NULL DEFAULT 0,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	rewarded_at     TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_referrals_referee ON referrals (referee_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer ON referrals (referrer_id, status);
+CREATE INDEX IF NOT EXISTS idx_referrals_device ON referrals (device_id) WHERE device_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_referrals_phone ON referrals (referee_phone);
+
+-- Kategori mutasi saldo reward referral
+INSERT INTO saldo_transaction_categories (id, code)
+VALUES (7, 'REFERRAL_REWARD')
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('REFERRAL_REWARD_REFERRER', 'Reward saldo untuk pengajak saat teman menyelesaikan order pertama (rupiah)', '25000', true, 'system', 'system'),
+	('REFERRAL_REWARD_REFEREE', 'Reward saldo untuk user baru saat menyelesaikan order pertama (rupiah)', '25000', true, 'system', 'system'),
+	('REFERRAL_MAX_PER_REFERRER', 'Maksimal referral yang diberi reward per pengajak (0 = tanpa batas)', '0', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
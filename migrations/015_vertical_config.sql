@@ -0,0 +1,35 @@
+-- Konfigurasi per vertical (job category): prefix nomor order, sebutan mitra,
+-- dokumen wajib registrasi, alur status order dan copy notifikasi.
+-- Kolom NULL → default di kode (lihat internal/mitra/dokter/vertical.go).
+ALTER TABLE job_categories
+	ADD COLUMN IF NOT EXISTS order_prefix       VARCHAR(6),
+	ADD COLUMN IF NOT EXISTS mitra_label        VARCHAR(50),
+	ADD COLUMN IF NOT EXISTS required_documents TEXT,  -- dipisah koma, mis. foto_ktp,foto_selfie_ktp,str,sip
+	ADD COLUMN IF NOT EXISTS status_flow        JSONB, -- {"1":[2],"2":[3],"3":[4]} (5 & 6 diabaikan)
+	ADD COLUMN IF NOT EXISTS notification_copy  JSONB; -- {"status_otw_title":"...", ...}
+
+-- Semua kategori yang sudah punya order berjalan dengan kode lama
+-- (nomor DOK-..., sebutan Dokter), apa pun namanya
+UPDATE job_categories
+SET order_prefix = COALESCE(order_prefix, 'DOK'),
+    mitra_label  = COALESCE(mitra_label, 'Dokter')
+WHERE id IN (
+	SELECT DISTINCT job_category_id
+	FROM service_orders
+	WHERE job_category_id IS NOT NULL
+);
+
+-- Vertical dokter: nomor order tetap DOK-... dan wajib STR / SIP
+UPDATE job_categories
+SET order_prefix       = COALESCE(order_prefix, 'DOK'),
+    mitra_label        = COALESCE(mitra_label, 'Dokter'),
+    required_documents = COALESCE(required_documents, 'foto,foto_ktp,foto_selfie_ktp,str,sip')
+WHERE name ILIKE '%dokter%';
+
+-- Contoh vertical baru (aktifkan dengan INSERT job_categories sesuai kebutuhan):
+-- UPDATE job_categories SET order_prefix = 'NRS', mitra_label = 'Perawat',
+--        required_documents = 'foto,foto_ktp,foto_selfie_ktp,str,sipp'
+-- WHERE name ILIKE '%perawat%';
+-- UPDATE job_categories SET order_prefix = 'FIS', mitra_label = 'Fisioterapis',
+--        required_documents = 'foto,foto_ktp,foto_selfie_ktp,str,sipf'
+-- WHERE name ILIKE '%fisio%';
@@ -0,0 +1,33 @@
+-- Review KYC dokumen mitra. Re-upload dokumen membuat baris baru,
+-- baris lama ditandai superseded_at; dokumen aktif = superseded_at IS NULL.
+ALTER TABLE mitra_documents
+	ADD COLUMN IF NOT EXISTS status         VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+	ADD COLUMN IF NOT EXISTS review_comment TEXT,
+	ADD COLUMN IF NOT EXISTS reviewed_by    BIGINT,
+	ADD COLUMN IF NOT EXISTS reviewed_at    TIMESTAMPTZ,
+	ADD COLUMN IF NOT EXISTS superseded_at  TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_mitra_documents_queue
+	ON mitra_documents (status, created_at)
+	WHERE superseded_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_mitra_documents_user
+	ON mitra_documents (user_id, doc_type)
+	WHERE superseded_at IS NULL;
+
+-- Status verifikasi mitra: PENDING → VERIFIED / REJECTED.
+-- Hanya VERIFIED yang bisa online dan menerima offer.
+ALTER TABLE mitra_details
+	ADD COLUMN IF NOT EXISTS verification_status VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+	ADD COLUMN IF NOT EXISTS verified_at         TIMESTAMPTZ;
+
+-- Mitra yang sudah berjalan sebelum review KYC dianggap terverifikasi
+UPDATE mitra_details
+SET verification_status = 'VERIFIED',
+    verified_at = COALESCE(verified_at, NOW())
+WHERE verification_status = 'PENDING';
+
+UPDATE mitra_documents
+SET status = 'APPROVED',
+    reviewed_at = COALESCE(reviewed_at, NOW())
+WHERE status = 'PENDING';
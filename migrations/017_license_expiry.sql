@@ -0,0 +1,15 @@
+-- Nomor & masa berlaku lisensi (STR / SIP) pada dokumen mitra
+ALTER TABLE mitra_documents
+	ADD COLUMN IF NOT EXISTS license_number            VARCHAR(60),
+	ADD COLUMN IF NOT EXISTS expires_at                DATE,
+	ADD COLUMN IF NOT EXISTS last_expiry_reminder_days INT; -- ambang reminder terakhir yang terkirim
+
+CREATE INDEX IF NOT EXISTS idx_mitra_documents_expiry
+	ON mitra_documents (expires_at)
+	WHERE superseded_at IS NULL AND status = 'APPROVED' AND expires_at IS NOT NULL;
+
+INSERT INTO global_parameter (parameter_code, parameter_name, parameter_value, is_active, created_by, updated_by)
+VALUES
+	('LICENSE_DOC_TYPES', 'Jenis dokumen lisensi yang wajib punya nomor & tanggal kedaluwarsa (dipisah koma)', 'str,sip', true, 'system', 'system'),
+	('LICENSE_EXPIRY_REMINDER_DAYS', 'Reminder perpanjangan lisensi H-N hari (dipisah koma)', '60,30,7', true, 'system', 'system')
+ON CONFLICT (parameter_code) DO NOTHING;
@@ -0,0 +1,6 @@
+-- Profil mitra yang bisa diubah sendiri: bio, foto, alamat praktik, spesialisasi
+ALTER TABLE mitra_details
+	ADD COLUMN IF NOT EXISTS bio               TEXT,
+	ADD COLUMN IF NOT EXISTS photo_object_key  TEXT, -- object MinIO, dihapus saat foto diganti
+	ADD COLUMN IF NOT EXISTS practice_address  TEXT,
+	ADD COLUMN IF NOT EXISTS specialities      JSONB NOT NULL DEFAULT '[]'::jsonb;
@@ -0,0 +1,5 @@
+-- File mitra & lampiran order disimpan sebagai object key di bucket private
+-- dan dibaca lewat presigned URL. mitra_documents.file_url lama yang masih
+-- berisi URL publik penuh tetap terbaca (prefix dibuang saat presign).
+COMMENT ON COLUMN mitra_documents.file_url IS 'object key di S3_BUCKET (data lama: URL publik)';
+COMMENT ON COLUMN order_attachments.url IS 'object key di bucket lampiran order';
@@ -0,0 +1,28 @@
+-- Upload langsung ke storage: server terbitkan presigned POST, client upload
+-- ke MinIO, lalu finalize (cek object, ukuran, magic bytes) dan tempel ke
+-- dokumen mitra / lampiran order / pesan chat.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+	id            BIGSERIAL PRIMARY KEY,
+	user_id       BIGINT       NOT NULL REFERENCES users(id),
+	purpose       VARCHAR(30)  NOT NULL, -- MITRA_DOCUMENT | ORDER_ATTACHMENT | CHAT_MESSAGE
+	doc_type      VARCHAR(50),           -- MITRA_DOCUMENT
+	order_id      BIGINT REFERENCES service_orders(id), -- ORDER_ATTACHMENT / CHAT_MESSAGE
+	bucket        VARCHAR(100) NOT NULL,
+	object_key    TEXT         NOT NULL UNIQUE,
+	content_type  VARCHAR(100) NOT NULL,
+	declared_size BIGINT       NOT NULL,
+	actual_size   BIGINT,
+	status        VARCHAR(20)  NOT NULL DEFAULT 'PENDING', -- PENDING | FINALIZED | FAILED
+	fail_reason   TEXT,
+	attachment_id BIGINT,                -- order_attachments.id hasil finalize
+	expires_at    TIMESTAMP    NOT NULL,
+	finalized_at  TIMESTAMP,
+	created_at    TIMESTAMP    NOT NULL DEFAULT NOW(),
+	updated_at    TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user ON upload_sessions (user_id, created_at DESC);
+
+-- content type lampiran (chat bisa berupa gambar atau PDF)
+ALTER TABLE order_attachments
+	ADD COLUMN IF NOT EXISTS content_type VARCHAR(100);
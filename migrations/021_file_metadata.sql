@@ -0,0 +1,22 @@
+-- Metadata file hasil pipeline validasi & proses upload: tipe asli (magic
+-- bytes), ukuran, dimensi gambar, hash SHA-256 isi yang disimpan dan
+-- object key thumbnail untuk tampilan list
+ALTER TABLE mitra_documents
+	ADD COLUMN IF NOT EXISTS content_type VARCHAR(100),
+	ADD COLUMN IF NOT EXISTS file_size    BIGINT,
+	ADD COLUMN IF NOT EXISTS width        INT,
+	ADD COLUMN IF NOT EXISTS height       INT,
+	ADD COLUMN IF NOT EXISTS sha256       CHAR(64),
+	ADD COLUMN IF NOT EXISTS thumb_key    TEXT;
+
+ALTER TABLE order_attachments
+	ADD COLUMN IF NOT EXISTS file_size BIGINT,
+	ADD COLUMN IF NOT EXISTS width     INT,
+	ADD COLUMN IF NOT EXISTS height    INT,
+	ADD COLUMN IF NOT EXISTS sha256    CHAR(64),
+	ADD COLUMN IF NOT EXISTS thumb_key TEXT;
+
+ALTER TABLE mitra_details
+	ADD COLUMN IF NOT EXISTS photo_thumb_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_mitra_documents_sha256 ON mitra_documents (sha256);
@@ -0,0 +1,14 @@
+-- Lampiran order sebagai bukti layanan: tipe photo | document | signature
+-- (chat untuk lampiran pesan), siapa yang upload dan kapan. Mitra boleh
+-- menambah lampiran selama status ARRIVED, tidak hanya saat complete.
+ALTER TABLE order_attachments
+	ADD COLUMN IF NOT EXISTS uploaded_by BIGINT REFERENCES users(id),
+	ADD COLUMN IF NOT EXISTS created_at  TIMESTAMP NOT NULL DEFAULT NOW();
+
+COMMENT ON COLUMN order_attachments.type IS 'photo | document | signature | chat';
+
+CREATE INDEX IF NOT EXISTS idx_order_attachments_order ON order_attachments (order_id, created_at);
+
+-- tipe lampiran untuk upload session ORDER_ATTACHMENT
+ALTER TABLE upload_sessions
+	ADD COLUMN IF NOT EXISTS attachment_type VARCHAR(20);
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	leaderRetryInterval = 15 * time.Second // instance standby coba ambil lock
+	leaderCheckInterval = 10 * time.Second // leader cek koneksi lock masih hidup
+)
+
+// RunAsLeader jalankan fn hanya di satu instance (replica) sekaligus memakai
+// Postgres session advisory lock bernama name.
+//
+// Lock dipegang oleh satu koneksi khusus; jika proses mati / koneksi putus
+// Postgres melepas lock otomatis dan instance lain mengambil alih pada retry
+// berikutnya. Jika koneksi leader putus, ctx fn dibatalkan sebelum lock
+// dicoba ulang. Blocking sampai ctx selesai.
+func RunAsLeader(ctx context.Context, db *gorm.DB, name string, fn func(ctx context.Context)) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("❌ leader %s: %v", name, err)
+		return
+	}
+
+	for {
+		if acquired := leadOnce(ctx, sqlDB, name, fn); !acquired {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(leaderRetryInterval):
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// leadOnce coba ambil lock lalu jalankan fn selama lock masih dipegang.
+// Return false jika lock sedang dipegang instance lain.
+func leadOnce(ctx context.Context, sqlDB *sql.DB, name string, fn func(ctx context.Context)) bool {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		log.Printf("❌ leader %s: get conn: %v", name, err)
+		return false
+	}
+	defer conn.Close()
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx,
+		`SELECT pg_try_advisory_lock(hashtext($1))`, name,
+	).Scan(&acquired); err != nil {
+		log.Printf("❌ leader %s: try lock: %v", name, err)
+		return false
+	}
+	if !acquired {
+		return false
+	}
+
+	log.Printf("👑 Leader lock %s acquired", name)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			cancel()
+			unlockLeader(conn, name)
+			return true
+
+		case <-ctx.Done():
+			cancel()
+			<-done
+			unlockLeader(conn, name)
+			return true
+
+		case <-ticker.C:
+			pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+			err := conn.PingContext(pingCtx)
+			pingCancel()
+			if err != nil {
+				// koneksi pemegang lock putus → lock sudah dilepas Postgres
+				log.Printf("⚠️ leader %s: lost lock connection: %v", name, err)
+				cancel()
+				<-done
+				return true
+			}
+		}
+	}
+}
+
+func unlockLeader(conn *sql.Conn, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, name); err != nil {
+		log.Printf("⚠️ leader %s: unlock: %v", name, err)
+		return
+	}
+	log.Printf("👑 Leader lock %s released", name)
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// IsAdmin user punya role admin / superadmin aktif
+func IsAdmin(ctx context.Context, db *gorm.DB, userID int64) (bool, error) {
+	var ok bool
+	err := db.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_roles ur
+			JOIN roles rl ON rl.id = ur.role_id
+			WHERE ur.user_id = ?
+			  AND ur.active = true
+			  AND LOWER(rl.name) IN ('admin', 'superadmin')
+		)
+	`, userID).Scan(&ok).Error
+	return ok, err
+}
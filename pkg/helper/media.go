@@ -0,0 +1,366 @@
+package helper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	// batas piksel untuk mencegah decompression bomb (± 40 MP)
+	maxImagePixels = 40_000_000
+	thumbMaxSide   = 320
+	jpegQuality    = 90
+	thumbQuality   = 80
+)
+
+var (
+	ErrUnsupportedFile = errors.New("tipe file tidak didukung, gunakan JPEG, PNG, WEBP atau PDF")
+	ErrFileTooLarge    = errors.New("ukuran file melebihi batas")
+	ErrCorruptFile     = errors.New("file rusak atau tidak dapat dibaca")
+)
+
+// ekstensi per content type hasil deteksi isi file
+var mediaExt = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// ProcessedFile file yang lolos validasi: metadata EXIF sudah dibuang dan
+// thumbnail JPEG dibuat untuk gambar
+type ProcessedFile struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	SHA256      string // hash isi yang disimpan (setelah diproses)
+	Thumbnail   []byte // nil untuk PDF
+}
+
+// StoredFile file yang sudah diproses dan tersimpan di storage
+type StoredFile struct {
+	Key         string
+	ThumbKey    string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	SHA256      string
+}
+
+// DetectMediaType content type dari isi file (magic bytes), bukan dari
+// header / ekstensi yang dikirim client
+func DetectMediaType(head []byte) string {
+	ct := strings.ToLower(strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0]))
+	if _, ok := mediaExt[ct]; !ok {
+		return ""
+	}
+	return ct
+}
+
+// ProcessMedia validasi tipe asli & ukuran, buang metadata (EXIF / GPS),
+// koreksi orientasi foto dan buat thumbnail
+func ProcessMedia(data []byte, maxBytes int64) (*ProcessedFile, error) {
+	if int64(len(data)) > maxBytes {
+		return nil, ErrFileTooLarge
+	}
+	if len(data) == 0 {
+		return nil, ErrCorruptFile
+	}
+
+	ct := DetectMediaType(data)
+	if ct == "" {
+		return nil, ErrUnsupportedFile
+	}
+
+	out := &ProcessedFile{ContentType: ct, Ext: mediaExt[ct]}
+
+	if ct == "application/pdf" {
+		if !validPDF(data) {
+			return nil, ErrCorruptFile
+		}
+		out.Data = data
+		out.SHA256 = sha256Hex(data)
+		return out, nil
+	}
+
+	img, err := decodeImage(data, ct)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ct {
+	case "image/jpeg":
+		// encode ulang = semua segmen APPn (EXIF, GPS, XMP) ikut terbuang
+		img = applyOrientation(img, jpegOrientation(data))
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		out.Data = buf.Bytes()
+
+	case "image/png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		out.Data = buf.Bytes()
+
+	case "image/webp":
+		// tidak ada encoder webp di stdlib: buang chunk EXIF / XMP saja
+		stripped, err := stripWebPMetadata(data)
+		if err != nil {
+			return nil, ErrCorruptFile
+		}
+		out.Data = stripped
+	}
+
+	b := img.Bounds()
+	out.Width, out.Height = b.Dx(), b.Dy()
+	out.SHA256 = sha256Hex(out.Data)
+
+	if out.Thumbnail, err = thumbnail(img); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func decodeImage(data []byte, ct string) (image.Image, error) {
+	var (
+		cfg image.Config
+		err error
+	)
+	switch ct {
+	case "image/webp":
+		cfg, err = webp.DecodeConfig(bytes.NewReader(data))
+	default:
+		cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, ErrCorruptFile
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("%w: resolusi gambar terlalu besar", ErrFileTooLarge)
+	}
+
+	var img image.Image
+	switch ct {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/webp":
+		img, err = webp.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, ErrCorruptFile
+	}
+	return img, nil
+}
+
+// validPDF header %PDF- dan penanda %%EOF di akhir file
+func validPDF(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return false
+	}
+	tail := data
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	return bytes.Contains(tail, []byte("%%EOF"))
+}
+
+// thumbnail JPEG sisi terpanjang thumbMaxSide px
+func thumbnail(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > thumbMaxSide || h > thumbMaxSide {
+		if w >= h {
+			h = h * thumbMaxSide / w
+			w = thumbMaxSide
+		} else {
+			w = w * thumbMaxSide / h
+			h = thumbMaxSide
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// latar putih untuk gambar transparan (JPEG tanpa alpha)
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// jpegOrientation baca tag EXIF Orientation (0x0112), 1 jika tidak ada
+func jpegOrientation(data []byte) int {
+	i := 2 // lewati SOI
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // SOS / EOI
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation putar / cerminkan piksel sesuai EXIF Orientation,
+// karena tag-nya ikut terbuang saat encode ulang
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	swap := orientation >= 5
+
+	dw, dh := w, h
+	if swap {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 CW
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 270 CW
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// stripWebPMetadata hapus chunk EXIF & XMP dari container RIFF WebP
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrCorruptFile
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrCorruptFile
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size + size%2 // chunk di-pad ke genap
+		if end > len(data) {
+			if i+8+size > len(data) {
+				return nil, ErrCorruptFile
+			}
+			end = len(data)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// dibuang
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // flag EXIF & XMP
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
@@ -1,50 +1,122 @@
 package helper
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"path"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 )
 
-// UploadFileToMinio uploads a file to MinIO and returns object path
+// UploadFileToMinio validasi & proses file (tipe asli, ukuran, buang EXIF,
+// thumbnail) lalu upload ke MinIO. Content-Type & ekstensi dari client
+// diabaikan.
 func UploadFileToMinio(
 	client *minio.Client,
 	bucketName string,
 	userDir string,
 	file *multipart.FileHeader,
-) (string, error) {
+	maxBytes int64,
+) (StoredFile, error) {
 
 	if file == nil {
-		return "", nil
+		return StoredFile{}, nil
+	}
+	if file.Size > maxBytes {
+		return StoredFile{}, ErrFileTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return StoredFile{}, err
+	}
+	defer f.Close()
+
+	// baca maksimal maxBytes+1 untuk deteksi file yang melebihi batas
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return StoredFile{}, err
+	}
+
+	processed, err := ProcessMedia(data, maxBytes)
+	if err != nil {
+		return StoredFile{}, err
 	}
 
 	objectName := fmt.Sprintf(
 		"%s/%s",
 		userDir,
-		GenerateRandomFileName(file.Filename), // ⬅️ TIDAK TAMBAH EXT LAGI
+		GenerateRandomFileName("file"+processed.Ext), // ekstensi dari isi file
 	)
 
-	f, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
+	return PutProcessedFile(client, bucketName, objectName, processed)
+}
 
-	_, err = client.PutObject(
+// PutProcessedFile simpan hasil ProcessMedia ke objectName (+ thumbnail
+// <nama>_thumb.jpg untuk gambar)
+func PutProcessedFile(client *minio.Client, bucketName, objectName string, processed *ProcessedFile) (StoredFile, error) {
+	if _, err := client.PutObject(
 		context.Background(),
 		bucketName,
 		objectName,
-		f,
-		file.Size,
-		minio.PutObjectOptions{
-			ContentType: file.Header.Get("Content-Type"),
-		},
-	)
+		bytes.NewReader(processed.Data),
+		int64(len(processed.Data)),
+		minio.PutObjectOptions{ContentType: processed.ContentType},
+	); err != nil {
+		return StoredFile{}, err
+	}
+
+	stored := StoredFile{
+		Key:         objectName,
+		ContentType: processed.ContentType,
+		Size:        int64(len(processed.Data)),
+		Width:       processed.Width,
+		Height:      processed.Height,
+		SHA256:      processed.SHA256,
+	}
+
+	if processed.Thumbnail != nil {
+		thumbKey := ThumbnailKey(objectName)
+		if _, err := client.PutObject(
+			context.Background(),
+			bucketName,
+			thumbKey,
+			bytes.NewReader(processed.Thumbnail),
+			int64(len(processed.Thumbnail)),
+			minio.PutObjectOptions{ContentType: "image/jpeg"},
+		); err != nil {
+			return StoredFile{}, err
+		}
+		stored.ThumbKey = thumbKey
+	}
+
+	return stored, nil
+}
+
+// ThumbnailKey object key thumbnail untuk object asli
+func ThumbnailKey(objectName string) string {
+	return strings.TrimSuffix(objectName, path.Ext(objectName)) + "_thumb.jpg"
+}
+
+// ReadObject baca seluruh object (maksimal maxBytes+1 byte)
+func ReadObject(client *minio.Client, bucketName, objectName string, maxBytes int64) ([]byte, error) {
+	obj, err := client.GetObject(context.Background(), bucketName, objectName, minio.GetObjectOptions{})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer obj.Close()
 
-	return objectName, nil
+	return io.ReadAll(io.LimitReader(obj, maxBytes+1))
+}
+
+// RemoveObjectFromMinio hapus object dari bucket (dipakai saat file diganti)
+func RemoveObjectFromMinio(client *minio.Client, bucketName, objectName string) error {
+	if objectName == "" {
+		return nil
+	}
+	return client.RemoveObject(context.Background(), bucketName, objectName, minio.RemoveObjectOptions{})
 }
@@ -0,0 +1,115 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// default masa berlaku presigned URL
+const defaultPresignTTL = 15 * time.Minute
+
+// PresignTTL masa berlaku presigned GET URL (S3_PRESIGN_TTL_MINUTES, default 15)
+func PresignTTL() time.Duration {
+	if val := os.Getenv("S3_PRESIGN_TTL_MINUTES"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			return time.Duration(n) * time.Minute
+		}
+	}
+	return defaultPresignTTL
+}
+
+// PresignGetURL URL sementara untuk membaca object di bucket private
+func PresignGetURL(client *minio.Client, bucketName, objectKey string, ttl time.Duration) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("storage tidak tersedia")
+	}
+	if objectKey == "" {
+		return "", nil
+	}
+
+	u, err := client.PresignedGetObject(context.Background(), bucketName, objectKey, ttl, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ObjectKeyFromURL ambil object key dari nilai yang tersimpan di DB.
+// Data lama menyimpan URL publik penuh (S3_PUBLIC_URL/<key> atau
+// https://<endpoint>/<bucket>/<key>), data baru sudah berupa key.
+func ObjectKeyFromURL(stored, bucketName string) string {
+	stored = strings.TrimSpace(stored)
+	if !strings.HasPrefix(stored, "http://") && !strings.HasPrefix(stored, "https://") {
+		return strings.TrimPrefix(stored, "/")
+	}
+
+	if publicURL := strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/"); publicURL != "" {
+		if strings.HasPrefix(stored, publicURL+"/") {
+			return strings.TrimPrefix(stored, publicURL+"/")
+		}
+	}
+
+	u, err := url.Parse(stored)
+	if err != nil {
+		return stored
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	return strings.TrimPrefix(path, bucketName+"/")
+}
+
+// PresignPostPolicy URL + field form untuk upload langsung ke storage
+// (multipart POST). Key, Content-Type dan rentang ukuran 1..maxSize byte
+// ditandatangani, sehingga storage menolak file yang lebih besar sebelum
+// tersimpan.
+func PresignPostPolicy(
+	client *minio.Client,
+	bucketName, objectKey, contentType string,
+	maxSize int64,
+	ttl time.Duration,
+) (string, map[string]string, error) {
+	if client == nil {
+		return "", nil, fmt.Errorf("storage tidak tersedia")
+	}
+
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(bucketName); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetKey(objectKey); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetContentType(contentType); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetContentLengthRange(1, maxSize); err != nil {
+		return "", nil, err
+	}
+	if err := policy.SetExpires(time.Now().UTC().Add(ttl)); err != nil {
+		return "", nil, err
+	}
+
+	u, fields, err := client.PresignedPostPolicy(context.Background(), policy)
+	if err != nil {
+		return "", nil, err
+	}
+	return u.String(), fields, nil
+}
+
+// StatObjectSize ukuran object, -1 jika object tidak ada
+func StatObjectSize(client *minio.Client, bucketName, objectKey string) (int64, error) {
+	info, err := client.StatObject(context.Background(), bucketName, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return -1, nil
+		}
+		return 0, err
+	}
+	return info.Size, nil
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"teka-api/pkg/database"
+)
+
+// AdminOnly hanya untuk user dengan role admin / superadmin aktif.
+// Dipasang setelah JWTProtected.
+func AdminOnly(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := UserID(c)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).
+				JSON(fiber.Map{"error": "unauthorized"})
+		}
+
+		ok, err := database.IsAdmin(c.Context(), db, int64(userID))
+		if err != nil {
+			log.Printf("❌ check admin %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(fiber.Map{"error": "gagal memeriksa role"})
+		}
+		if !ok {
+			return c.Status(fiber.StatusForbidden).
+				JSON(fiber.Map{"error": "khusus admin"})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// CodeChars karakter kode voucher / referral, tanpa 0/O/1/I supaya mudah dibaca
+const CodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// RandomCode prefix + n karakter acak (crypto/rand) dari CodeChars
+func RandomCode(prefix string, n int) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+
+	max := big.NewInt(int64(len(CodeChars)))
+	for i := 0; i < n; i++ {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(CodeChars[idx.Int64()])
+	}
+
+	return b.String(), nil
+}
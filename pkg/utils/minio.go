@@ -38,17 +38,38 @@ func InitMinio() *minio.Client {
 		return nil
 	}
 
+	ctx := context.Background()
+
 	bucket := os.Getenv("S3_BUCKET")
 	if bucket == "" {
 		log.Println("⚠️ S3_BUCKET not set, skipping bucket check")
-		return client
+	} else {
+		ensureBucket(ctx, client, bucket)
 	}
 
-	ctx := context.Background()
+	// bucket lampiran order bisa sama dengan S3_BUCKET
+	if attachment := AttachmentBucket(); attachment != bucket {
+		ensureBucket(ctx, client, attachment)
+	}
+
+	return client
+}
+
+// AttachmentBucket bucket lampiran / bukti order (S3_ATTACHMENT_BUCKET),
+// default "uploads" untuk data lama
+func AttachmentBucket() string {
+	if b := strings.TrimSpace(os.Getenv("S3_ATTACHMENT_BUCKET")); b != "" {
+		return b
+	}
+	return "uploads"
+}
+
+// ensureBucket cek bucket ada (buat jika S3_ALLOW_CREATE=true) dan private
+func ensureBucket(ctx context.Context, client *minio.Client, bucket string) {
 	exists, err := client.BucketExists(ctx, bucket)
 	if err != nil {
-		log.Println("⚠️ Bucket check failed:", err)
-		return client
+		log.Printf("⚠️ Bucket check %s failed: %v", bucket, err)
+		return
 	}
 
 	if !exists {
@@ -59,14 +80,40 @@ func InitMinio() *minio.Client {
 			}); err != nil {
 				log.Println("⚠️ Failed to create bucket, you must create it manually:", err)
 			} else {
+				// bucket baru tanpa policy = private
 				fmt.Println("✅ Bucket successfully created:", bucket)
 			}
 		} else {
 			log.Println("⚠️ Bucket does not exist. Create manually or set S3_ALLOW_CREATE=true")
 		}
-	} else {
-		fmt.Println("✅ MinIO ready, bucket exists:", bucket)
+		return
 	}
 
-	return client
+	fmt.Println("✅ MinIO ready, bucket exists:", bucket)
+	ensurePrivateBucket(ctx, client, bucket)
+}
+
+// ensurePrivateBucket bucket berisi dokumen KYC / bukti order tidak boleh
+// punya policy anonymous read; file diakses lewat presigned URL. Policy yang
+// ada dihapus kecuali S3_ALLOW_PUBLIC_BUCKET=true (opt-out eksplisit).
+func ensurePrivateBucket(ctx context.Context, client *minio.Client, bucket string) {
+	policy, err := client.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		log.Printf("⚠️ Bucket policy check %s failed: %v", bucket, err)
+		return
+	}
+	if policy == "" {
+		return
+	}
+
+	if os.Getenv("S3_ALLOW_PUBLIC_BUCKET") == "true" {
+		log.Printf("⚠️ Bucket %s has a public policy and S3_ALLOW_PUBLIC_BUCKET=true, KYC files are publicly readable", bucket)
+		return
+	}
+
+	if err := client.SetBucketPolicy(ctx, bucket, ""); err != nil {
+		log.Printf("⚠️ Failed to make bucket %s private: %v", bucket, err)
+		return
+	}
+	fmt.Println("🔒 Bucket policy removed, bucket is private:", bucket)
 }
@@ -0,0 +1,144 @@
+-- Benchmark query kandidat SearchDoctors: full scan haversine (lama) vs
+-- prefilter bounding box GiST (baru).
+--
+-- Jalankan di database dev:
+--   psql "$DATABASE_URL" -f scripts/bench/search_doctors.sql
+--
+-- Semua tabel dibuat di schema bench_search lalu di-drop di akhir,
+-- data asli di myschema tidak disentuh.
+
+\timing on
+
+DROP SCHEMA IF EXISTS bench_search CASCADE;
+CREATE SCHEMA bench_search;
+SET search_path TO bench_search;
+
+\set n_mitra 50000
+\set lat -6.2
+\set lng 106.816666
+\set radius 10
+
+CREATE TABLE users (id BIGINT PRIMARY KEY, nama TEXT);
+CREATE TABLE user_roles (user_id BIGINT, role_id INT, active BOOLEAN);
+CREATE TABLE mitra_details (
+	user_id BIGINT PRIMARY KEY,
+	job_category_id BIGINT,
+	job_sub_category_id BIGINT,
+	availability_status_id INT,
+	current_latitude DOUBLE PRECISION,
+	current_longitude DOUBLE PRECISION,
+	current_location POINT,
+	location_updated_at TIMESTAMPTZ
+);
+CREATE TABLE request_mitra_offers (id BIGSERIAL PRIMARY KEY, request_id BIGINT, mitra_id BIGINT, status_id INT);
+CREATE TABLE service_orders (id BIGSERIAL PRIMARY KEY, mitra_id BIGINT, status_id INT);
+
+-- mitra tersebar acak di ± Pulau Jawa (lat -8.5..-5.9, lng 105..114.5),
+-- 60% online, 5 kategori
+INSERT INTO users (id, nama)
+SELECT g, 'Dokter ' || g FROM generate_series(1, :n_mitra) g;
+
+INSERT INTO user_roles (user_id, role_id, active)
+SELECT g, 2, true FROM generate_series(1, :n_mitra) g;
+
+INSERT INTO mitra_details
+SELECT
+	g,
+	1 + (g % 5),
+	0,
+	CASE WHEN random() < 0.6 THEN 2 ELSE 1 END,
+	lat,
+	lng,
+	point(lng, lat),
+	NOW() - (random() * INTERVAL '10 minutes')
+FROM (
+	SELECT g, -8.5 + random() * 2.6 AS lat, 105 + random() * 9.5 AS lng
+	FROM generate_series(1, :n_mitra) g
+) s;
+
+INSERT INTO request_mitra_offers (request_id, mitra_id, status_id)
+SELECT g / 3, 1 + (random() * (:n_mitra - 1))::BIGINT, 1 + (random() * 5)::INT
+FROM generate_series(1, :n_mitra * 4) g;
+
+INSERT INTO service_orders (mitra_id, status_id)
+SELECT 1 + (random() * (:n_mitra - 1))::BIGINT, 1 + (random() * 5)::INT
+FROM generate_series(1, :n_mitra * 2) g;
+
+CREATE INDEX ON user_roles (user_id);
+CREATE INDEX ON request_mitra_offers (mitra_id, status_id);
+CREATE INDEX ON request_mitra_offers (request_id, mitra_id);
+CREATE INDEX ON service_orders (mitra_id) WHERE status_id IN (1,2,3);
+CREATE INDEX ON mitra_details USING GIST (current_location) WHERE availability_status_id = 2;
+
+ANALYZE;
+
+-- ======================================================================
+-- LAMA: acos untuk setiap baris mitra_details
+-- ======================================================================
+EXPLAIN (ANALYZE, BUFFERS)
+SELECT *
+FROM (
+	SELECT
+		u.id AS mitra_id,
+		(
+			6371 * acos(
+				cos(radians(:lat)) *
+				cos(radians(md.current_latitude)) *
+				cos(radians(md.current_longitude) - radians(:lng)) +
+				sin(radians(:lat)) *
+				sin(radians(md.current_latitude))
+			)
+		) AS distance_km
+	FROM mitra_details md
+	JOIN users u ON u.id = md.user_id
+	JOIN user_roles ur ON ur.user_id = u.id
+	WHERE md.job_category_id = 1
+	  AND md.availability_status_id = 2
+	  AND ur.role_id = 2
+	  AND ur.active = true
+	  AND NOT EXISTS (SELECT 1 FROM request_mitra_offers rmo WHERE rmo.mitra_id = u.id AND rmo.status_id = 1)
+	  AND NOT EXISTS (SELECT 1 FROM service_orders so WHERE so.mitra_id = u.id AND so.status_id IN (1,2,3))
+) t
+WHERE t.distance_km <= :radius
+ORDER BY t.distance_km ASC;
+
+-- ======================================================================
+-- BARU: bounding box (lihat boundingBox di internal/mitra/dokter/geo.go)
+-- lalu haversine hanya untuk kandidat di dalam kotak
+-- ======================================================================
+\set dlat '(:radius / 111.045)'
+\set dlng '(:radius / (111.045 * cos(radians(:lat))))'
+
+EXPLAIN (ANALYZE, BUFFERS)
+SELECT *
+FROM (
+	SELECT
+		u.id AS mitra_id,
+		(
+			6371 * acos(LEAST(1,
+				cos(radians(:lat)) *
+				cos(radians(md.current_latitude)) *
+				cos(radians(md.current_longitude) - radians(:lng)) +
+				sin(radians(:lat)) *
+				sin(radians(md.current_latitude))
+			))
+		) AS distance_km
+	FROM mitra_details md
+	JOIN users u ON u.id = md.user_id
+	JOIN user_roles ur ON ur.user_id = u.id
+	WHERE md.current_location <@ box(
+			point(:lng - :dlng, :lat - :dlat),
+			point(:lng + :dlng, :lat + :dlat)
+		)
+	  AND md.job_category_id = 1
+	  AND md.availability_status_id = 2
+	  AND ur.role_id = 2
+	  AND ur.active = true
+	  AND NOT EXISTS (SELECT 1 FROM request_mitra_offers rmo WHERE rmo.mitra_id = u.id AND rmo.status_id = 1)
+	  AND NOT EXISTS (SELECT 1 FROM service_orders so WHERE so.mitra_id = u.id AND so.status_id IN (1,2,3))
+) t
+WHERE t.distance_km <= :radius
+ORDER BY t.distance_km ASC;
+
+RESET search_path;
+DROP SCHEMA bench_search CASCADE;